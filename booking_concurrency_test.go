@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"cineverse/controllers"
+	"cineverse/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the Postgres database named by TEST_DATABASE_DSN and
+// migrates it. The booking tests need row locks and the unique seat index, so
+// they are skipped without one.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connecting to test database: %v", err)
+	}
+	if err := migrate(db); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	return db
+}
+
+// TestCreateBookingSameSeatsConcurrently books the same seats from many
+// goroutines at once: exactly one booking may win, every other request must be
+// turned away with the seats it conflicted on.
+func TestCreateBookingSameSeatsConcurrently(t *testing.T) {
+	db := testDB(t)
+	gin.SetMode(gin.TestMode)
+
+	const attempts = 25
+	suffix := time.Now().UnixNano()
+	seats := []string{"A1", "A2"}
+
+	theatre := models.Theatre{Name: "Concurrency Test", Location: "Test"}
+	if err := db.Create(&theatre).Error; err != nil {
+		t.Fatal(err)
+	}
+	screen := models.Screen{Name: "Screen 1", SeatsTotal: 20, TheatreID: theatre.ID}
+	if err := db.Create(&screen).Error; err != nil {
+		t.Fatal(err)
+	}
+	movie := models.Movie{Title: "Concurrency Test", DurationMin: "120"}
+	if err := db.Create(&movie).Error; err != nil {
+		t.Fatal(err)
+	}
+	show := models.Show{MovieID: movie.ID, ScreenID: screen.ID, StartTime: time.Now().Add(48 * time.Hour), Price: 200, SeatsTotal: 20}
+	if err := db.Create(&show).Error; err != nil {
+		t.Fatal(err)
+	}
+	users := make([]models.User, attempts)
+	for i := range users {
+		users[i] = models.User{FullName: "Test User", Email: fmt.Sprintf("race-%d-%d@example.com", suffix, i), Password: "x"}
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		bookings := db.Model(&models.Booking{}).Select("id").Where("show_id = ?", show.ID)
+		db.Where("booking_id IN (?)", bookings).Delete(&models.BookingCharge{})
+		db.Where("show_id = ?", show.ID).Delete(&models.BookingSeat{})
+		db.Where("show_id = ?", show.ID).Delete(&models.Booking{})
+		db.Unscoped().Delete(&show)
+		db.Unscoped().Delete(&movie)
+		db.Delete(&screen)
+		db.Delete(&theatre)
+		db.Unscoped().Delete(&users)
+	})
+
+	router := gin.New()
+	router.POST("/bookings", func(c *gin.Context) {
+		var userID uint
+		fmt.Sscan(c.GetHeader("X-User"), &userID)
+		c.Set("userId", userID)
+	}, controllers.CreateBooking(db))
+
+	body, _ := json.Marshal(gin.H{"show_id": show.ID, "seat_codes": seats})
+	type result struct {
+		code int
+		body map[string]interface{}
+	}
+	results := make([]result, attempts)
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/bookings", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-User", fmt.Sprint(users[i].ID))
+			rec := httptest.NewRecorder()
+			<-start
+			router.ServeHTTP(rec, req)
+			results[i].code = rec.Code
+			json.Unmarshal(rec.Body.Bytes(), &results[i].body)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created := 0
+	for i, res := range results {
+		switch res.code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			var conflicting []string
+			if list, ok := res.body["conflicting_seats"].([]interface{}); ok {
+				for _, code := range list {
+					conflicting = append(conflicting, fmt.Sprint(code))
+				}
+			}
+			sort.Strings(conflicting)
+			if fmt.Sprint(conflicting) != fmt.Sprint(seats) {
+				t.Errorf("request %d: conflicting seats %v, want %v", i, conflicting, seats)
+			}
+		default:
+			t.Errorf("request %d: status %d, body %v", i, res.code, res.body)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d bookings created for the same seats, want exactly 1", created)
+	}
+
+	var active int64
+	db.Model(&models.BookingSeat{}).Where("show_id = ? AND active = ?", show.ID, true).Count(&active)
+	if active != int64(len(seats)) {
+		t.Fatalf("%d active seat rows, want %d", active, len(seats))
+	}
+}
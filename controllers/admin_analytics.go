@@ -20,15 +20,29 @@ type TheatreRevenueBreakdown struct {
 	TotalRevenue   float64 `json:"total_revenue"`
 	ParkingRevenue float64 `json:"parking_revenue"`
 	TicketRevenue  float64 `json:"ticket_revenue"`
+	FoodRevenue    float64 `json:"food_revenue"`
+	FeeRevenue     float64 `json:"fee_revenue"`
+	TaxRevenue     float64 `json:"tax_revenue"`
 }
 
+// bookingChargeTotals sums the tax and fee lines of each booking. Taxes included in
+// parking prices are counted apart so they can be taken out of parking revenue.
+const bookingChargeTotals = `(SELECT booking_id,
+	SUM(CASE WHEN kind = 'fee' THEN amount ELSE 0 END) AS fees,
+	SUM(CASE WHEN kind = 'tax' THEN amount ELSE 0 END) AS taxes,
+	SUM(CASE WHEN kind = 'tax' AND applies_to = 'parking' THEN amount ELSE 0 END) AS parking_taxes
+	FROM booking_charges GROUP BY booking_id) bc`
+
 func (ac *AnalyticsController) GetTheatreRevenueAnalytics(c *gin.Context) {
 	var rawResults []TheatreRevenueBreakdown
 
 	// Fetch revenue grouped by Screen (which includes Theatre info via joins)
 	err := ac.DB.
 		Table("bookings").
-		Select("t.id AS theatre_id, t.name AS theatre_name, s.id AS screen_id, s.name AS screen_name, SUM(bookings.total_amount) AS total_revenue, SUM(bookings.parking_fee) AS parking_revenue").
+		Select("t.id AS theatre_id, t.name AS theatre_name, s.id AS screen_id, s.name AS screen_name, SUM(bookings.total_amount) AS total_revenue, "+
+			"SUM(bookings.parking_fee - COALESCE(bc.parking_taxes, 0)) AS parking_revenue, SUM(bookings.food_amount) AS food_revenue, "+
+			"COALESCE(SUM(bc.fees), 0) AS fee_revenue, COALESCE(SUM(bc.taxes), 0) AS tax_revenue").
+		Joins("LEFT JOIN "+bookingChargeTotals+" ON bc.booking_id = bookings.id").
 		Joins("JOIN shows sh ON sh.id = bookings.show_id").
 		Joins("JOIN screens s ON s.id = sh.screen_id").
 		Joins("JOIN theatres t ON t.id = s.theatre_id").
@@ -49,14 +63,17 @@ func (ac *AnalyticsController) GetTheatreRevenueAnalytics(c *gin.Context) {
 		TotalRevenue   float64                    `json:"total_revenue"`
 		ParkingRevenue float64                    `json:"parking_revenue"`
 		TicketRevenue  float64                    `json:"ticket_revenue"`
+		FoodRevenue    float64                    `json:"food_revenue"`
+		FeeRevenue     float64                    `json:"fee_revenue"`
+		TaxRevenue     float64                    `json:"tax_revenue"`
 		Screens        []*TheatreRevenueBreakdown `json:"screens"`
 	}
 
 	theatreMap := make(map[uint]*TheatreAggregate)
 
 	for _, result := range rawResults {
-		// Calculate Ticket Revenue for the screen
-		result.TicketRevenue = result.TotalRevenue - result.ParkingRevenue
+		// Calculate Ticket Revenue for the screen: what is left once parking, food, fees and taxes are taken out
+		result.TicketRevenue = result.TotalRevenue - result.ParkingRevenue - result.FoodRevenue - result.FeeRevenue - result.TaxRevenue
 
 		// Initialize theatre entry if it doesn't exist
 		if _, ok := theatreMap[result.TheatreID]; !ok {
@@ -71,6 +88,9 @@ func (ac *AnalyticsController) GetTheatreRevenueAnalytics(c *gin.Context) {
 		theatreMap[result.TheatreID].TotalRevenue += result.TotalRevenue
 		theatreMap[result.TheatreID].ParkingRevenue += result.ParkingRevenue
 		theatreMap[result.TheatreID].TicketRevenue += result.TicketRevenue
+		theatreMap[result.TheatreID].FoodRevenue += result.FoodRevenue
+		theatreMap[result.TheatreID].FeeRevenue += result.FeeRevenue
+		theatreMap[result.TheatreID].TaxRevenue += result.TaxRevenue
 
 		// Add screen breakdown to the theatre's list (pass a copy)
 		screenData := result
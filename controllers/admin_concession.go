@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type concessionPayload struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Category    string              `json:"category"`
+	Price       float64             `json:"price"`
+	Stock       *int                `json:"stock"`
+	Components  []models.ComboEntry `json:"components"`
+	Active      *bool               `json:"active"`
+}
+
+// apply copies the payload onto an item and validates the result.
+func (p *concessionPayload) apply(db *gorm.DB, item *models.ConcessionItem) error {
+	item.Name = strings.TrimSpace(p.Name)
+	item.Description = p.Description
+	item.Category = strings.ToLower(strings.TrimSpace(p.Category))
+	item.Price = p.Price
+	item.Stock = p.Stock
+	item.Components = p.Components
+	if p.Active != nil {
+		item.Active = *p.Active
+	}
+	return utils.ValidateConcessionItem(db, item)
+}
+
+// AdminListConcessions — Admin: a theatre's whole menu, inactive items included
+func AdminListConcessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var items []models.ConcessionItem
+		if err := db.Where("theatre_id = ?", c.Param("id")).Order("category, name").Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch menu"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items})
+	}
+}
+
+// AdminCreateConcession — Admin: add an item or combo to a theatre's menu
+func AdminCreateConcession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload concessionPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu item data"})
+			return
+		}
+
+		var theatre models.Theatre
+		if err := db.First(&theatre, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Theatre not found"})
+			return
+		}
+
+		item := models.ConcessionItem{TheatreID: theatre.ID, Active: true}
+		if err := payload.apply(db, &item); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create menu item"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Menu item created successfully", "item": item})
+	}
+}
+
+// AdminUpdateConcession — Admin: edit a menu item, e.g. to restock it. Orders
+// already placed keep the prices they were sold at.
+func AdminUpdateConcession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload concessionPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu item data"})
+			return
+		}
+
+		var item models.ConcessionItem
+		if err := db.First(&item, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu item not found"})
+			return
+		}
+		if err := payload.apply(db, &item); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update menu item"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Menu item updated successfully", "item": item})
+	}
+}
+
+// AdminDeleteConcession — Admin: remove a menu item. Items that combos still
+// contain cannot be removed; deactivate them instead.
+func AdminDeleteConcession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var item models.ConcessionItem
+		if err := db.First(&item, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Menu item not found"})
+			return
+		}
+
+		var combos []models.ConcessionItem
+		if err := db.Where("theatre_id = ? AND id <> ?", item.TheatreID, item.ID).Find(&combos).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check combos"})
+			return
+		}
+		for _, combo := range combos {
+			for _, entry := range combo.Components {
+				if entry.ItemID == item.ID {
+					c.JSON(http.StatusConflict, gin.H{"error": "Item is part of the combo " + combo.Name})
+					return
+				}
+			}
+		}
+
+		if err := db.Delete(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete menu item"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Menu item deleted successfully"})
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"cineverse/models"
+	"cineverse/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -149,6 +151,15 @@ func UpdateBookingStatus(db *gorm.DB) gin.HandlerFunc {
 
 		oldStatus := booking.Status
 
+		// Only the payment webhook confirms bookings; a paid one may be restored
+		if body.Status == "confirmed" && oldStatus != "confirmed" {
+			var payment models.Payment
+			if err := db.Where("booking_id = ? AND status IN ?", booking.ID, []string{utils.PaymentCaptured, utils.PaymentPartiallyRefunded}).First(&payment).Error; err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Bookings are confirmed by the payment gateway once paid"})
+				return
+			}
+		}
+
 		tx := db.Begin()
 		defer func() {
 			if r := recover(); r != nil {
@@ -156,12 +167,54 @@ func UpdateBookingStatus(db *gorm.DB) gin.HandlerFunc {
 			}
 		}()
 
+		if err := lockBookingShow(tx, booking.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show"})
+			return
+		}
+
 		booking.Status = body.Status
 		if err := tx.Save(&booking).Error; err != nil {
+			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
 			return
 		}
 
+		// Only pending and confirmed bookings hold their seats, and group reservations;
+		// seats exchanged or refunded away stay released
+		wasActive := oldStatus == "pending" || oldStatus == "confirmed" || oldStatus == "reserved"
+		isActive := body.Status == "pending" || body.Status == "confirmed"
+		if wasActive != isActive {
+			if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ? AND exchanged_at IS NULL AND refunded_at IS NULL", booking.ID).Update("active", isActive).Error; err != nil {
+				tx.Rollback()
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					c.JSON(http.StatusConflict, gin.H{"error": "Seats of this booking have since been taken by another booking"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking seats"})
+				return
+			}
+		}
+		// Seats transferred out of a booking go with it, as it paid for them
+		var transferredSeats []string
+		if oldStatus == "confirmed" && !isActive {
+			var err error
+			if transferredSeats, err = cancelTransferredBookings(tx, booking.ID); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel transferred seats"})
+				return
+			}
+		}
+		var offers []waitlistOffer
+		if wasActive && !isActive {
+			var err error
+			if offers, err = offerWaitlistSeats(tx, booking.ShowID); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to offer seats to the waitlist"})
+				return
+			}
+		}
+
 		if oldStatus != "confirmed" && body.Status == "confirmed" {
 			// Transition to confirmed: Increment count
 			var show models.Show
@@ -185,6 +238,18 @@ func UpdateBookingStatus(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if oldStatus != body.Status {
+			switch {
+			case body.Status == "confirmed":
+				utils.SeatEvents.Publish(booking.ShowID, "booked", bookingSeatCodes(db, booking.ID))
+			case body.Status == "pending":
+				utils.SeatEvents.Publish(booking.ShowID, "held", bookingSeatCodes(db, booking.ID))
+			case wasActive:
+				utils.SeatEvents.Publish(booking.ShowID, "released", append(bookingSeatCodes(db, booking.ID), transferredSeats...))
+			}
+		}
+		publishWaitlistOffers(offers)
+
 		c.JSON(http.StatusOK, gin.H{"message": "Booking status updated successfully", "booking": booking})
 	}
 }
@@ -207,6 +272,11 @@ func DeleteBooking(db *gorm.DB) gin.HandlerFunc {
 			}
 		}()
 
+		if err := lockBookingShow(tx, id); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show"})
+			return
+		}
 		var booking models.Booking
 		if err := tx.Preload("Payment").First(&booking, id).Error; err != nil {
 			tx.Rollback()
@@ -221,12 +291,16 @@ func DeleteBooking(db *gorm.DB) gin.HandlerFunc {
 			paymentStatus = strings.ToLower(booking.Payment.Status)
 		}
 
-		if status == "confirmed" || paymentStatus == "completed" {
+		if status == "confirmed" || utils.IsPaymentCaptured(paymentStatus) {
 			tx.Rollback()
 			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete a confirmed or paid booking"})
 			return
 		}
 
+		// Remember which seats are freed so live seat maps can be told
+		var releasedSeats []string
+		tx.Model(&models.BookingSeat{}).Where("booking_id = ? AND active = ?", id, true).Pluck("seat_code", &releasedSeats)
+
 		// Delete associated seats
 		if err := tx.Where("booking_id = ?", id).Delete(&models.BookingSeat{}).Error; err != nil {
 			tx.Rollback()
@@ -240,12 +314,20 @@ func DeleteBooking(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete booking"})
 			return
 		}
+		offers, err := offerWaitlistSeats(tx, booking.ShowID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to offer seats to the waitlist"})
+			return
+		}
 
 		if err := tx.Commit().Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 			return
 		}
+		utils.SeatEvents.Publish(booking.ShowID, "released", releasedSeats)
+		publishWaitlistOffers(offers)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Booking deleted successfully"})
 	}
@@ -260,7 +342,12 @@ func GetBookingDetails(db *gorm.DB) gin.HandlerFunc {
 		// Fetch booking with all related data
 		if err := db.Preload("User").
 			Preload("Seats").
-			Preload("Payment").
+			Preload("Payment.Transitions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Preload("Payment.Refunds").
+			Preload("Exchanges").
+			Preload("Invoice", "food_order_id IS NULL").
+			Preload("Discounts").
+			Preload("Charges").
 			Preload("Show.Movie").
 			Preload("Show.Screen.Theatre").
 			First(&booking, id).Error; err != nil {
@@ -270,6 +357,10 @@ func GetBookingDetails(db *gorm.DB) gin.HandlerFunc {
 
 		c.JSON(http.StatusOK, gin.H{
 			"booking": booking,
+			"documents": gin.H{
+				"ticket_pdf":  fmt.Sprintf("/api/admin/bookings/%d/ticket.pdf", booking.ID),
+				"invoice_pdf": fmt.Sprintf("/api/admin/bookings/%d/invoice.pdf", booking.ID),
+			},
 		})
 	}
 }
@@ -370,6 +461,19 @@ func AdminAddShow(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Screens with a seat layout dictate the capacity of their shows
+		var seatLayout models.SeatLayout
+		if err := db.Where("screen_id = ?", screen.ID).First(&seatLayout).Error; err == nil {
+			capacity := len(bookableSeats(seatLayout))
+			if payload.SeatsTotal > capacity {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Screen layout only has %d seats", capacity)})
+				return
+			}
+			if payload.SeatsTotal == 0 {
+				payload.SeatsTotal = capacity
+			}
+		}
+
 		// Parse Start Time
 		startTime, err := time.Parse(time.RFC3339, payload.StartTime)
 		if err != nil {
@@ -462,7 +566,7 @@ func AdminDeleteShow(db *gorm.DB) gin.HandlerFunc {
 
 		var activeBookingsCount int64
 		err := db.Model(&models.Booking{}).
-			Where("show_id = ? AND status IN (?, ?)", id, "confirmed", "pending").
+			Where("show_id = ? AND status IN (?, ?, ?)", id, "confirmed", "pending", "reserved").
 			Count(&activeBookingsCount).Error
 
 		if err != nil {
@@ -515,7 +619,7 @@ func AdminEditShow(db *gorm.DB) gin.HandlerFunc {
 
 		var activeBookingsCount int64
 		db.Model(&models.Booking{}).
-			Where("show_id = ? AND status IN (?, ?)", id, "confirmed", "pending").
+			Where("show_id = ? AND status IN (?, ?, ?)", id, "confirmed", "pending", "reserved").
 			Count(&activeBookingsCount)
 
 		if activeBookingsCount > 0 {
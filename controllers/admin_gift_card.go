@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxGiftCardBatch caps how many gift cards one request may issue.
+const maxGiftCardBatch = 500
+
+// AdminIssueGiftCards — Admin: issue one or more gift cards of the same value
+func AdminIssueGiftCards(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Amount    float64    `json:"amount"`
+			Count     int        `json:"count"`
+			ExpiresAt *time.Time `json:"expires_at"`
+			Note      string     `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gift card data"})
+			return
+		}
+		if req.Count == 0 {
+			req.Count = 1
+		}
+		if req.Count < 0 || req.Count > maxGiftCardBatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Count must be between 1 and 500"})
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+			return
+		}
+
+		cards := make([]*models.GiftCard, 0, req.Count)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < req.Count; i++ {
+				card, err := utils.IssueGiftCard(tx, req.Amount, req.ExpiresAt, req.Note, adminID(c))
+				if err != nil {
+					return err
+				}
+				cards = append(cards, card)
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue gift cards"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Gift cards issued", "gift_cards": cards})
+	}
+}
+
+// AdminListGiftCards — Admin: gift cards with their remaining value, newest first
+// (?status=unredeemed|redeemed|expired).
+func AdminListGiftCards(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Account").Order("created_at DESC")
+		switch c.Query("status") {
+		case "":
+		case "unredeemed":
+			query = query.Where("redeemed_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+		case "redeemed":
+			query = query.Where("redeemed_at IS NOT NULL")
+		case "expired":
+			query = query.Where("redeemed_at IS NULL AND expires_at <= ?", time.Now())
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown gift card status"})
+			return
+		}
+
+		var cards []models.GiftCard
+		if err := query.Find(&cards).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch gift cards"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"gift_cards": cards})
+	}
+}
+
+// AdminAuditLedger — Admin: check that the wallet ledger is consistent. The
+// system account balances show the money held in wallets and gift cards.
+func AdminAuditLedger(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		issues, err := utils.AuditLedger(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to audit ledger"})
+			return
+		}
+
+		var system []models.LedgerAccount
+		if err := db.Where("kind = ?", "system").Order("code").Find(&system).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger accounts"})
+			return
+		}
+		var held []struct {
+			Kind    string  `json:"kind"`
+			Count   int64   `json:"count"`
+			Balance float64 `json:"balance"`
+		}
+		if err := db.Model(&models.LedgerAccount{}).Select("kind, COUNT(*) AS count, COALESCE(SUM(balance), 0) AS balance").
+			Where("kind <> ?", "system").Group("kind").Scan(&held).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger accounts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"consistent":      len(issues) == 0,
+			"issues":          issues,
+			"system_accounts": system,
+			"held":            held,
+		})
+	}
+}
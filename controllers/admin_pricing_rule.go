@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type pricingRulePayload struct {
+	Name           string  `json:"name"`
+	Priority       int     `json:"priority"`
+	Active         *bool   `json:"active"`
+	MovieID        *uint   `json:"movie_id"`
+	TheatreID      *uint   `json:"theatre_id"`
+	ShowID         *uint   `json:"show_id"`
+	Days           []int   `json:"days"`
+	FromTime       string  `json:"from_time"`
+	ToTime         string  `json:"to_time"`
+	MinHoursBefore int     `json:"min_hours_before"`
+	MinOccupancy   float64 `json:"min_occupancy"`
+	AdjustmentType string  `json:"adjustment_type"`
+	Value          float64 `json:"value"`
+}
+
+// apply copies the payload onto a rule and validates the result.
+func (p *pricingRulePayload) apply(rule *models.PricingRule) error {
+	rule.Name = strings.TrimSpace(p.Name)
+	rule.Priority = p.Priority
+	if p.Active != nil {
+		rule.Active = *p.Active
+	}
+	rule.MovieID = p.MovieID
+	rule.TheatreID = p.TheatreID
+	rule.ShowID = p.ShowID
+	rule.Days = p.Days
+	rule.FromTime = strings.TrimSpace(p.FromTime)
+	rule.ToTime = strings.TrimSpace(p.ToTime)
+	rule.MinHoursBefore = p.MinHoursBefore
+	rule.MinOccupancy = p.MinOccupancy
+	rule.AdjustmentType = p.AdjustmentType
+	rule.Value = p.Value
+	return utils.ValidatePricingRule(rule)
+}
+
+// AdminListPricingRules — Admin: all pricing rules in the order they are tried
+func AdminListPricingRules(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rules []models.PricingRule
+		if err := db.Order("priority DESC, min_occupancy DESC, id").Find(&rules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pricing rules"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"rules": rules})
+	}
+}
+
+// AdminCreatePricingRule — Admin: add a pricing rule
+func AdminCreatePricingRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload pricingRulePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pricing rule data"})
+			return
+		}
+
+		rule := models.PricingRule{Active: true}
+		if err := payload.apply(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pricing rule"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Pricing rule created successfully", "rule": rule})
+	}
+}
+
+// AdminUpdatePricingRule — Admin: edit a pricing rule. Seats already booked keep
+// the price they were sold at.
+func AdminUpdatePricingRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload pricingRulePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pricing rule data"})
+			return
+		}
+
+		var rule models.PricingRule
+		if err := db.First(&rule, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+		if err := payload.apply(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pricing rule"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Pricing rule updated successfully", "rule": rule})
+	}
+}
+
+// AdminDeletePricingRule — Admin: remove a pricing rule
+func AdminDeletePricingRule(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.PricingRule{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pricing rule"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Pricing rule deleted successfully"})
+	}
+}
+
+// AdminGetShowPricing — Admin: the rules that could apply to a show and the one
+// that applies if it is booked now.
+func AdminGetShowPricing(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var show models.Show
+		if err := db.First(&show, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+
+		rules, err := utils.LoadPricingRules(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pricing rules"})
+			return
+		}
+		applied := utils.MatchPricingRule(rules, &show, time.Now())
+
+		occupancy := 0.0
+		if show.SeatsTotal > 0 {
+			occupancy = utils.RoundMoney(float64(show.SeatsBooked) * 100 / float64(show.SeatsTotal))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"show_id":         show.ID,
+			"base_price":      show.Price,
+			"effective_price": utils.ApplyPricingRule(applied, show.Price),
+			"occupancy":       occupancy,
+			"applied_rule":    applied,
+			"candidate_rules": rules,
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type promotionPayload struct {
+	Code         string     `json:"code"`
+	Description  string     `json:"description"`
+	DiscountType string     `json:"discount_type"`
+	Value        float64    `json:"value"`
+	MaxDiscount  float64    `json:"max_discount"`
+	MinSeats     int        `json:"min_seats"`
+	MovieID      *uint      `json:"movie_id"`
+	TheatreID    *uint      `json:"theatre_id"`
+	ShowID       *uint      `json:"show_id"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	UsageLimit   int        `json:"usage_limit"`
+	PerUserLimit int        `json:"per_user_limit"`
+	Active       *bool      `json:"active"`
+}
+
+// apply copies the payload onto a promotion and validates the result.
+func (p *promotionPayload) apply(promo *models.Promotion) error {
+	promo.Code = utils.NormalizePromoCode(p.Code)
+	promo.Description = p.Description
+	promo.DiscountType = p.DiscountType
+	promo.Value = p.Value
+	promo.MaxDiscount = p.MaxDiscount
+	promo.MinSeats = p.MinSeats
+	promo.MovieID = p.MovieID
+	promo.TheatreID = p.TheatreID
+	promo.ShowID = p.ShowID
+	promo.StartsAt = p.StartsAt
+	promo.EndsAt = p.EndsAt
+	promo.UsageLimit = p.UsageLimit
+	promo.PerUserLimit = p.PerUserLimit
+	if p.Active != nil {
+		promo.Active = *p.Active
+	}
+
+	switch {
+	case promo.Code == "":
+		return errors.New("promo code is required")
+	case promo.DiscountType != "percent" && promo.DiscountType != "flat":
+		return errors.New(`discount type must be "percent" or "flat"`)
+	case promo.Value <= 0:
+		return errors.New("discount value must be positive")
+	case promo.DiscountType == "percent" && promo.Value > 100:
+		return errors.New("a percent discount cannot exceed 100")
+	case promo.MaxDiscount < 0 || promo.MinSeats < 0 || promo.UsageLimit < 0 || promo.PerUserLimit < 0:
+		return errors.New("limits cannot be negative")
+	case promo.StartsAt != nil && promo.EndsAt != nil && !promo.EndsAt.After(*promo.StartsAt):
+		return errors.New("the offer must end after it starts")
+	}
+	return nil
+}
+
+// AdminListPromotions — Admin: all promo codes, newest first
+func AdminListPromotions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Order("created_at DESC")
+		if c.Query("active") == "true" {
+			query = query.Where("active = ?", true)
+		}
+
+		var promotions []models.Promotion
+		if err := query.Find(&promotions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"promotions": promotions})
+	}
+}
+
+// AdminCreatePromotion — Admin: add a promo code
+func AdminCreatePromotion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload promotionPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion data"})
+			return
+		}
+
+		promo := models.Promotion{Active: true}
+		if err := payload.apply(&promo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&promo).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Promo code already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promotion"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Promotion created successfully", "promotion": promo})
+	}
+}
+
+// AdminUpdatePromotion — Admin: edit a promo code. Bookings that already used it
+// keep the discount they got.
+func AdminUpdatePromotion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload promotionPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion data"})
+			return
+		}
+
+		var promo models.Promotion
+		if err := db.First(&promo, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+		if err := payload.apply(&promo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&promo).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Promo code already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promotion"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Promotion updated successfully", "promotion": promo})
+	}
+}
+
+// AdminDeletePromotion — Admin: remove a promo code. The discount lines of
+// bookings that used it are kept.
+func AdminDeletePromotion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.Promotion{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promotion"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted successfully"})
+	}
+}
+
+// AdminGetPromotionStats — Admin: how a promo code performed. Redemptions count
+// confirmed bookings; live holds are reported apart.
+func AdminGetPromotionStats(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var promo models.Promotion
+		if err := db.First(&promo, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		var stats struct {
+			Redemptions   int64   `json:"redemptions"`
+			UniqueUsers   int64   `json:"unique_users"`
+			TotalDiscount float64 `json:"total_discount"`
+			Revenue       float64 `json:"revenue"` // paid by the bookings that used the code
+		}
+		if err := db.Model(&models.BookingDiscount{}).
+			Select("COUNT(*) AS redemptions, COUNT(DISTINCT bookings.user_id) AS unique_users, "+
+				"COALESCE(SUM(booking_discounts.amount), 0) AS total_discount, COALESCE(SUM(bookings.total_amount), 0) AS revenue").
+			Joins("JOIN bookings ON bookings.id = booking_discounts.booking_id").
+			Where("booking_discounts.promotion_id = ? AND bookings.status = ?", promo.ID, "confirmed").
+			Scan(&stats).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotion stats"})
+			return
+		}
+
+		now := time.Now()
+		var held int64
+		if err := db.Model(&models.BookingDiscount{}).
+			Joins("JOIN bookings ON bookings.id = booking_discounts.booking_id").
+			Where("booking_discounts.promotion_id = ? AND bookings.status = ? AND (bookings.expires_at IS NULL OR bookings.expires_at > ?)", promo.ID, "pending", now).
+			Count(&held).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotion stats"})
+			return
+		}
+
+		var remaining interface{}
+		if promo.UsageLimit > 0 {
+			remaining = max(int64(promo.UsageLimit)-stats.Redemptions-held, 0)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"promotion":      promo,
+			"redemptions":    stats.Redemptions,
+			"pending":        held,
+			"remaining":      remaining, // null when unlimited
+			"unique_users":   stats.UniqueUsers,
+			"total_discount": utils.RoundMoney(stats.TotalDiscount),
+			"revenue":        utils.RoundMoney(stats.Revenue),
+		})
+	}
+}
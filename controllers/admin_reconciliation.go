@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// adminID is the ID of the signed-in admin, for audit columns.
+func adminID(c *gin.Context) *uint {
+	if id := c.GetUint("userId"); id != 0 {
+		return &id
+	}
+	return nil
+}
+
+// AdminImportSettlement — Admin: upload a gateway settlement CSV ("file") for
+// reconciliation. The gateway defaults to the active one.
+func AdminImportSettlement(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gateway := strings.TrimSpace(c.PostForm("gateway"))
+		if gateway == "" {
+			gateway = utils.ActivePaymentGateway().Name()
+		}
+		if _, ok := utils.PaymentGatewayByName(gateway); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown payment gateway"})
+			return
+		}
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Settlement file is required"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read settlement file"})
+			return
+		}
+		defer file.Close()
+
+		imported, err := utils.ImportSettlementCSV(db, gateway, header.Filename, file, adminID(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settlement file: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Settlement imported", "import": imported})
+	}
+}
+
+// AdminRunReconciliation — Admin: compare bookings, payments and the imported
+// settlement records now, optionally fixing the safe mismatches.
+func AdminRunReconciliation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			AutoFix bool `json:"auto_fix"`
+		}
+		_ = c.ShouldBindJSON(&req)
+
+		run, err := utils.RunReconciliation(db, "admin", adminID(c), req.AutoFix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Reconciliation failed"})
+			return
+		}
+		db.Where("run_id = ?", run.ID).Order("id").Find(&run.Issues)
+
+		c.JSON(http.StatusCreated, run)
+	}
+}
+
+// AdminListReconciliationRuns — Admin: past reconciliation runs, newest first.
+func AdminListReconciliationRuns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var runs []models.ReconciliationRun
+		if err := db.Order("started_at desc").Limit(100).Find(&runs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation runs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, runs)
+	}
+}
+
+// AdminGetReconciliationRun — Admin: one run with its issues, optionally only
+// those of one kind (?kind=amount_mismatch) or still open (?open=true).
+func AdminGetReconciliationRun(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var run models.ReconciliationRun
+		if err := db.First(&run, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation run not found"})
+			return
+		}
+
+		query := db.Where("run_id = ?", run.ID)
+		if kind := c.Query("kind"); kind != "" {
+			query = query.Where("kind = ?", kind)
+		}
+		if c.Query("open") == "true" {
+			query = query.Where("fixed = ?", false)
+		}
+		if err := query.Order("id").Find(&run.Issues).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch issues"})
+			return
+		}
+
+		c.JSON(http.StatusOK, run)
+	}
+}
@@ -0,0 +1,428 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// paymentRefundTotals sums the refund ledger of a payment.
+func paymentRefundTotals(db *gorm.DB, payment *models.Payment) (refunded, refundable float64, err error) {
+	if err = db.Model(&models.Refund{}).Where("payment_id = ?", payment.ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+		return 0, 0, err
+	}
+	refunded = utils.RoundMoney(refunded)
+	return refunded, utils.RoundMoney(payment.Amount - refunded), nil
+}
+
+// AdminRefundBooking — Admin: return money on a paid booking. The refund can be
+//
+//	"full"    everything not yet refunded; a confirmed booking is cancelled
+//	"partial" any amount, booking unchanged (e.g. a goodwill gesture)
+//	"seats"   the price of the given seats, which are released from the booking
+//	"parking" the parking fee, which is removed from the booking
+//
+// The payment row is locked while the ledger is checked, so refunds never add up
+// to more than was captured.
+func AdminRefundBooking(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Type      string   `json:"type"`
+			Amount    float64  `json:"amount"`     // "partial" only
+			SeatCodes []string `json:"seat_codes"` // "seats" only
+			Reason    string   `json:"reason"`
+			Note      string   `json:"note"`
+			RefundTo  string   `json:"refund_to"` // "wallet" (default) or "source"
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund data"})
+			return
+		}
+		destination, ok := refundDestination(req.RefundTo)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `Refunds go to "wallet" or "source"`})
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "admin_" + req.Type
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		if err := lockBookingShow(tx, c.Param("id")); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show"})
+			return
+		}
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, c.Param("id")).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if booking.SourceBookingID != nil {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Seats transferred out of booking #%d are refunded through it", *booking.SourceBookingID)})
+			return
+		}
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_id = ?", booking.ID).First(&payment).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Booking has no payment to refund"})
+			return
+		}
+		if !utils.IsPaymentCaptured(payment.Status) {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + payment.Status + " payment cannot be refunded"})
+			return
+		}
+
+		_, refundable, err := paymentRefundTotals(tx, &payment)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load refund ledger"})
+			return
+		}
+
+		refund := models.Refund{
+			PaymentID: payment.ID,
+			BookingID: booking.ID,
+			Type:      req.Type,
+			Reason:    req.Reason,
+			Note:      req.Note,
+		}
+		if adminID := c.GetUint("userId"); adminID != 0 {
+			refund.AdminID = &adminID
+		}
+
+		bookingUpdates := map[string]interface{}{}
+		var releasedSeats []string
+		var releasedSeatIDs []uint
+		var transferredSeats []string // released with the bookings they were transferred to
+		cancelBooking := false
+		pointsBack := 0 // redeemed loyalty points to give back
+
+		switch req.Type {
+		case "full":
+			refund.Amount = refundable
+			refund.ParkingAmount = min(booking.ParkingFee, refundable)
+			refund.FoodAmount = min(booking.FoodAmount, utils.RoundMoney(refundable-refund.ParkingAmount))
+			refund.SeatAmount = utils.RoundMoney(refundable - refund.ParkingAmount - refund.FoodAmount)
+			cancelBooking = booking.Status == "confirmed" || booking.Status == "pending"
+			pointsBack = booking.PointsUsed
+
+		case "partial":
+			if req.Amount <= 0 {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+				return
+			}
+			refund.Amount = utils.RoundMoney(req.Amount)
+			refund.SeatAmount = refund.Amount
+
+		case "seats":
+			seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+			if !ok || len(seatCodes) == 0 {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Seat codes are required and must be unique"})
+				return
+			}
+			var seats []models.BookingSeat
+			if err := tx.Where("booking_id = ? AND active = ? AND seat_code IN ?", booking.ID, true, seatCodes).Find(&seats).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking seats"})
+				return
+			}
+			if len(seats) != len(seatCodes) {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Some seats are not part of this booking"})
+				return
+			}
+			var seatAmount float64
+			for _, seat := range seats {
+				if seat.AdmittedAt != nil {
+					tx.Rollback()
+					c.JSON(http.StatusConflict, gin.H{"error": "Seat " + seat.SeatCode + " was already used"})
+					return
+				}
+				seatAmount += seat.Price
+				releasedSeats = append(releasedSeats, seat.SeatCode)
+				releasedSeatIDs = append(releasedSeatIDs, seat.ID)
+			}
+			remaining := booking.SeatsCount - len(seats)
+			// the seats give up their share of the booking's discount and of what
+			// loyalty points paid, and take their taxes and fees with them; the
+			// points are given back
+			ratio := 1.0
+			if remaining > 0 {
+				ratio = seatAmount / (booking.TotalAmount - booking.ParkingFee - booking.FoodAmount - booking.Fees - booking.Taxes + booking.Discount + booking.PointsValue)
+			}
+			if booking.Discount > 0 || booking.PointsValue > 0 {
+				discountShare := utils.RoundMoney(booking.Discount * ratio)
+				pointsShare := utils.RoundMoney(booking.PointsValue * ratio)
+				seatAmount -= discountShare + pointsShare
+				bookingUpdates["discount"] = utils.RoundMoney(booking.Discount - discountShare)
+				bookingUpdates["points_value"] = utils.RoundMoney(booking.PointsValue - pointsShare)
+				pointsBack = int(math.Round(float64(booking.PointsUsed) * ratio))
+			}
+			if booking.Fees > 0 || booking.Taxes > 0 {
+				feesShare := utils.RoundMoney(booking.Fees * ratio)
+				taxesShare := utils.RoundMoney(booking.Taxes * ratio)
+				seatAmount += feesShare + taxesShare
+				bookingUpdates["fees"] = utils.RoundMoney(booking.Fees - feesShare)
+				bookingUpdates["taxes"] = utils.RoundMoney(booking.Taxes - taxesShare)
+			}
+			if err := tx.Model(&models.BookingCharge{}).Where("booking_id = ? AND applies_to = ?", booking.ID, "tickets").
+				Updates(map[string]interface{}{
+					"base":   gorm.Expr("ROUND(base * ?, 2)", 1-ratio),
+					"amount": gorm.Expr("ROUND(amount * ?, 2)", 1-ratio),
+				}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update taxes and fees"})
+				return
+			}
+			refund.Amount = utils.RoundMoney(seatAmount)
+			refund.SeatAmount = refund.Amount
+			refund.SeatCodes = releasedSeats
+
+			bookingUpdates["seats_count"] = remaining
+			bookingUpdates["total_amount"] = utils.RoundMoney(booking.TotalAmount - seatAmount)
+			cancelBooking = remaining == 0
+
+		case "parking":
+			if !booking.HasParking || booking.ParkingFee <= 0 {
+				tx.Rollback()
+				c.JSON(http.StatusConflict, gin.H{"error": "Booking has no parking to refund"})
+				return
+			}
+			refund.Amount = utils.RoundMoney(booking.ParkingFee)
+			refund.ParkingAmount = refund.Amount
+			if err := tx.Where("booking_id = ? AND applies_to = ?", booking.ID, "parking").Delete(&models.BookingCharge{}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update taxes and fees"})
+				return
+			}
+			bookingUpdates["has_parking"] = false
+			bookingUpdates["parking_fee"] = 0
+			bookingUpdates["total_amount"] = utils.RoundMoney(booking.TotalAmount - booking.ParkingFee)
+
+		default:
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": `Refund type must be "full", "partial", "seats" or "parking"`})
+			return
+		}
+
+		if refund.Amount <= 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Nothing left to refund on this payment"})
+			return
+		}
+		if refund.Amount > refundable {
+			tx.Rollback()
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":      fmt.Sprintf("Refund of %.2f exceeds the %.2f still refundable", refund.Amount, refundable),
+				"refundable": refundable,
+			})
+			return
+		}
+
+		// Seats and parking given up by the booking
+		wasConfirmed := booking.Status == "confirmed"
+		if cancelBooking {
+			bookingUpdates["status"] = "cancelled"
+			if err := utils.CancelFoodOrders(tx, []uint{booking.ID}); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel food orders"})
+				return
+			}
+			if req.Type == "full" {
+				releasedSeats = bookingSeatCodes(tx, booking.ID)
+				if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ?", booking.ID).Update("active", false).Error; err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release seats"})
+					return
+				}
+				// the money refunded also paid for seats transferred out of it
+				if transferredSeats, err = cancelTransferredBookings(tx, booking.ID); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel transferred seats"})
+					return
+				}
+			}
+		}
+		if len(releasedSeatIDs) > 0 {
+			if err := tx.Model(&models.BookingSeat{}).Where("id IN ?", releasedSeatIDs).
+				Updates(map[string]interface{}{"active": false, "refunded_at": time.Now()}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release seats"})
+				return
+			}
+		}
+		if len(bookingUpdates) > 0 {
+			bookingUpdates["updated_at"] = time.Now()
+			if err := tx.Model(&booking).Updates(bookingUpdates).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+				return
+			}
+		}
+		var offers []waitlistOffer
+		if len(releasedSeats) > 0 || len(transferredSeats) > 0 {
+			if offers, err = offerWaitlistSeats(tx, booking.ShowID); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to offer seats to the waitlist"})
+				return
+			}
+		}
+		if wasConfirmed && len(releasedSeats) > 0 {
+			if err := tx.Model(&models.Show{}).
+				Where("id = ? AND seats_booked >= ?", booking.ShowID, len(releasedSeats)).
+				Update("seats_booked", gorm.Expr("seats_booked - ?", len(releasedSeats))).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seats count"})
+				return
+			}
+		}
+
+		if err := refundPayment(tx, &payment, &refund, destination); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Refund failed: " + err.Error()})
+			return
+		}
+
+		if err := tx.Create(&refund).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record refund"})
+			return
+		}
+		if err := markRefunded(tx, &payment, "admin refund #"+fmt.Sprint(refund.ID)); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
+			return
+		}
+
+		// Loyalty points earned on the refunded seats are taken back, all of them
+		// if the booking is cancelled
+		note := "admin refund #" + fmt.Sprint(refund.ID)
+		reversedOn := refund.SeatAmount
+		if cancelBooking {
+			reversedOn = booking.TotalAmount
+		}
+		if err := utils.ReverseLoyaltyPoints(tx, &booking, reversedOn, note); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update loyalty points"})
+			return
+		}
+		if err := utils.RestoreLoyaltyPoints(tx, &booking, pointsBack, note); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore loyalty points"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		utils.SeatEvents.Publish(booking.ShowID, "released", append(releasedSeats, transferredSeats...))
+		publishWaitlistOffers(offers)
+		settleRefund(db, &refund)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":        "Refund processed",
+			"refund":         refund,
+			"payment_status": payment.Status,
+			"refundable":     utils.RoundMoney(refundable - refund.Amount),
+		})
+	}
+}
+
+// AdminGetBookingRefunds — Admin: the refund ledger of a booking's payment.
+func AdminGetBookingRefunds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payment models.Payment
+		if err := db.Preload("Refunds", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+			Where("booking_id = ?", c.Param("id")).
+			First(&payment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Booking has no payment"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+			return
+		}
+
+		refunded, refundable, err := paymentRefundTotals(db, &payment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"payment_id":     payment.ID,
+			"payment_status": payment.Status,
+			"captured":       payment.Amount,
+			"refunded":       refunded,
+			"refundable":     refundable,
+			"refunds":        payment.Refunds,
+		})
+	}
+}
+
+// AdminListRefunds — Admin: the refund ledger across all payments, newest first,
+// optionally filtered by type and by date (?from=2006-01-02&to=2006-01-02).
+func AdminListRefunds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.Refund{})
+
+		if refundType := strings.TrimSpace(c.Query("type")); refundType != "" {
+			query = query.Where("type = ?", refundType)
+		}
+		if from := c.Query("from"); from != "" {
+			t, err := time.Parse("2006-01-02", from)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+				return
+			}
+			query = query.Where("created_at >= ?", t)
+		}
+		if to := c.Query("to"); to != "" {
+			t, err := time.Parse("2006-01-02", to)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+				return
+			}
+			query = query.Where("created_at < ?", t.AddDate(0, 0, 1))
+		}
+
+		var refunds []models.Refund
+		if err := query.Order("created_at desc").Find(&refunds).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+			return
+		}
+
+		var total float64
+		for _, refund := range refunds {
+			total += refund.Amount
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"refunds": refunds,
+			"count":   len(refunds),
+			"total":   utils.RoundMoney(total),
+		})
+	}
+}
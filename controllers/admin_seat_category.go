@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cineverse/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type seatCategoryPayload struct {
+	Name  string   `json:"name"`
+	Price float64  `json:"price"`
+	Rows  []string `json:"rows"`
+	Seats []string `json:"seats"`
+}
+
+// validateSeatCategory checks a category against the screen layout and the other
+// categories of the screen, so every row and seat belongs to at most one category.
+func validateSeatCategory(db *gorm.DB, screen *models.Screen, category *models.SeatCategory) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return errors.New("category name is required")
+	}
+	if category.Price < 0 {
+		return errors.New("category price cannot be negative")
+	}
+
+	layout, err := loadScreenLayout(db, screen.ID, screen.SeatsTotal)
+	if err != nil {
+		return err
+	}
+	rows := make(map[string]bool)
+	for _, row := range layout.Rows {
+		rows[row.Label] = true
+	}
+	seats := bookableSeats(layout)
+
+	for i, row := range category.Rows {
+		category.Rows[i] = strings.ToUpper(strings.TrimSpace(row))
+		if !rows[category.Rows[i]] {
+			return fmt.Errorf("row %s is not part of the screen layout", category.Rows[i])
+		}
+	}
+	for i, seat := range category.Seats {
+		category.Seats[i] = strings.ToUpper(strings.TrimSpace(seat))
+		if !seats[category.Seats[i]] {
+			return fmt.Errorf("seat %s is not part of the screen layout", category.Seats[i])
+		}
+	}
+
+	var others []models.SeatCategory
+	if err := db.Where("screen_id = ? AND id <> ?", screen.ID, category.ID).Find(&others).Error; err != nil {
+		return err
+	}
+	for _, other := range others {
+		for _, row := range category.Rows {
+			for _, taken := range other.Rows {
+				if row == taken {
+					return fmt.Errorf("row %s already belongs to category %s", row, other.Name)
+				}
+			}
+		}
+		for _, seat := range category.Seats {
+			for _, taken := range other.Seats {
+				if seat == taken {
+					return fmt.Errorf("seat %s already belongs to category %s", seat, other.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetScreenCategories — Admin: list seat categories of a screen
+func GetScreenCategories(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var categories []models.SeatCategory
+		if err := db.Where("screen_id = ?", c.Param("id")).Order("id ASC").Find(&categories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seat categories"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"categories": categories})
+	}
+}
+
+// CreateSeatCategory — Admin: add a seat category to a screen
+func CreateSeatCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload seatCategoryPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category data"})
+			return
+		}
+
+		var screen models.Screen
+		if err := db.First(&screen, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Screen not found"})
+			return
+		}
+
+		category := models.SeatCategory{
+			ScreenID: screen.ID,
+			Name:     payload.Name,
+			Price:    payload.Price,
+			Rows:     payload.Rows,
+			Seats:    payload.Seats,
+		}
+		if err := validateSeatCategory(db, &screen, &category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create seat category"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Seat category created successfully", "category": category})
+	}
+}
+
+// UpdateSeatCategory — Admin: edit a seat category
+func UpdateSeatCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload seatCategoryPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category data"})
+			return
+		}
+
+		var category models.SeatCategory
+		if err := db.First(&category, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Seat category not found"})
+			return
+		}
+
+		var screen models.Screen
+		if err := db.First(&screen, category.ScreenID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Screen not found"})
+			return
+		}
+
+		category.Name = payload.Name
+		category.Price = payload.Price
+		category.Rows = payload.Rows
+		category.Seats = payload.Seats
+		if err := validateSeatCategory(db, &screen, &category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seat category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Seat category updated successfully", "category": category})
+	}
+}
+
+// DeleteSeatCategory — Admin: remove a seat category; its seats fall back to Show.Price
+func DeleteSeatCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("seat_category_id = ?", id).Delete(&models.ShowCategoryPrice{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.SeatCategory{}, id).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete seat category"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Seat category deleted successfully"})
+	}
+}
+
+// GetShowCategoryPrices — Admin: effective price of every seat category for a show
+func GetShowCategoryPrices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var show models.Show
+		if err := db.First(&show, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+
+		var categories []models.SeatCategory
+		if err := db.Where("screen_id = ?", show.ScreenID).Order("id ASC").Find(&categories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seat categories"})
+			return
+		}
+
+		overrides, err := loadPriceOverrides(db, show.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch show prices"})
+			return
+		}
+
+		var prices []gin.H
+		for _, category := range categories {
+			_, overridden := overrides[category.ID]
+			prices = append(prices, gin.H{
+				"category_id": category.ID,
+				"category":    category.Name,
+				"price":       categoryPrice(&show, category, overrides),
+				"overridden":  overridden,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"show_id": show.ID, "base_price": show.Price, "prices": prices})
+	}
+}
+
+// SetShowCategoryPrices — Admin: override category prices for a show
+func SetShowCategoryPrices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		showID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID"})
+			return
+		}
+
+		var payload struct {
+			Prices []struct {
+				CategoryID uint    `json:"category_id"`
+				Price      float64 `json:"price"`
+			} `json:"prices"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil || len(payload.Prices) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price data"})
+			return
+		}
+
+		var show models.Show
+		if err := db.First(&show, showID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+
+		var rows []models.ShowCategoryPrice
+		for _, p := range payload.Prices {
+			if p.Price <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Prices must be greater than zero"})
+				return
+			}
+
+			var category models.SeatCategory
+			if err := db.Where("id = ? AND screen_id = ?", p.CategoryID, show.ScreenID).First(&category).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Seat category %d does not belong to this show's screen", p.CategoryID)})
+				return
+			}
+
+			rows = append(rows, models.ShowCategoryPrice{
+				ShowID:         show.ID,
+				SeatCategoryID: category.ID,
+				Price:          p.Price,
+				UpdatedAt:      time.Now(),
+			})
+		}
+
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "show_id"}, {Name: "seat_category_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"price", "updated_at"}),
+		}).Create(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save show prices"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Show prices updated successfully", "prices": rows})
+	}
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cineverse/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxSeatsPerRow = 100
+
+// validateSeatRows checks that a layout is drawable and that every seat code it
+// produces is unambiguous. Row labels are upper-cased in place.
+func validateSeatRows(rows []models.SeatRow) error {
+	if len(rows) == 0 {
+		return errors.New("layout must have at least one row")
+	}
+
+	labels := make(map[string]bool)
+	for i := range rows {
+		row := &rows[i]
+		row.Label = strings.ToUpper(strings.TrimSpace(row.Label))
+
+		if row.Label == "" {
+			return fmt.Errorf("row %d has no label", i+1)
+		}
+		for _, r := range row.Label {
+			if r < 'A' || r > 'Z' {
+				return fmt.Errorf("row label %q must contain letters only", row.Label)
+			}
+		}
+		if labels[row.Label] {
+			return fmt.Errorf("row label %q is used twice", row.Label)
+		}
+		labels[row.Label] = true
+
+		if row.Seats <= 0 || row.Seats > maxSeatsPerRow {
+			return fmt.Errorf("row %s must have between 1 and %d seats", row.Label, maxSeatsPerRow)
+		}
+		for _, positions := range [][]int{row.Aisles, row.Gaps, row.Disabled} {
+			for _, pos := range positions {
+				if pos <= 0 || pos > row.Seats {
+					return fmt.Errorf("row %s has no position %d", row.Label, pos)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetScreenLayout — Admin: seat layout of a screen, or the default grid if none is set
+func GetScreenLayout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var screen models.Screen
+		if err := db.First(&screen, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Screen not found"})
+			return
+		}
+
+		var layout models.SeatLayout
+		err := db.Where("screen_id = ?", screen.ID).First(&layout).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			layout = defaultSeatLayout(screen.SeatsTotal)
+			layout.ScreenID = screen.ID
+			c.JSON(http.StatusOK, gin.H{"layout": layout, "capacity": len(bookableSeats(layout)), "default": true})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seat layout"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"layout": layout, "capacity": len(bookableSeats(layout)), "default": false})
+	}
+}
+
+// CreateScreenLayout — Admin: attach a seat layout to a screen
+func CreateScreenLayout(db *gorm.DB) gin.HandlerFunc {
+	return saveScreenLayout(db, true)
+}
+
+// UpdateScreenLayout — Admin: replace the seat layout of a screen
+func UpdateScreenLayout(db *gorm.DB) gin.HandlerFunc {
+	return saveScreenLayout(db, false)
+}
+
+func saveScreenLayout(db *gorm.DB, create bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		screenID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid screen ID"})
+			return
+		}
+
+		var payload struct {
+			Rows []models.SeatRow `json:"rows"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid layout data"})
+			return
+		}
+		if err := validateSeatRows(payload.Rows); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var screen models.Screen
+		if err := db.First(&screen, screenID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Screen not found"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		var layout models.SeatLayout
+		err = tx.Where("screen_id = ?", screen.ID).First(&layout).Error
+		switch {
+		case err == nil && create:
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Screen already has a seat layout"})
+			return
+		case errors.Is(err, gorm.ErrRecordNotFound) && !create:
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Screen has no seat layout"})
+			return
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seat layout"})
+			return
+		}
+
+		layout.ScreenID = screen.ID
+		layout.Rows = payload.Rows
+		seats := bookableSeats(layout)
+
+		// Seats already sold or held for upcoming shows must survive the change
+		var soldSeats []string
+		if err := tx.Table("booking_seats").
+			Joins("JOIN shows ON shows.id = booking_seats.show_id").
+			Where("shows.screen_id = ? AND shows.start_time >= ? AND booking_seats.active = ?", screen.ID, time.Now(), true).
+			Distinct().
+			Pluck("booking_seats.seat_code", &soldSeats).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check booked seats"})
+			return
+		}
+
+		var missing []string
+		for _, code := range soldSeats {
+			if !seats[code] {
+				missing = append(missing, code)
+			}
+		}
+		if len(missing) > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error":          "Layout removes seats booked for upcoming shows: " + strings.Join(missing, ", "),
+				"affected_seats": missing,
+			})
+			return
+		}
+
+		if err := tx.Save(&layout).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save seat layout"})
+			return
+		}
+
+		// The layout is now the source of truth for capacity
+		capacity := len(seats)
+		if err := tx.Model(&screen).Update("seats_total", capacity).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update screen capacity"})
+			return
+		}
+		if err := tx.Model(&models.Show{}).
+			Where("screen_id = ? AND start_time >= ?", screen.ID, time.Now()).
+			Update("seats_total", capacity).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update show capacity"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		status := http.StatusOK
+		if create {
+			status = http.StatusCreated
+		}
+		c.JSON(status, gin.H{
+			"message":  "Seat layout saved successfully",
+			"layout":   layout,
+			"capacity": capacity,
+		})
+	}
+}
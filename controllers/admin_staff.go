@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Roles of admin accounts. Staff log in through the admin login but only reach
+// the gate routes (check-in and food collection).
+const (
+	roleAdmin = "admin"
+	roleStaff = "staff"
+)
+
+// AdminListStaff — Admin: the admin and staff accounts, staff first.
+func AdminListStaff(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var admins []models.Admin
+		if err := db.Order("role desc, full_name").Find(&admins).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"accounts": admins})
+	}
+}
+
+// AdminCreateStaff — Admin: open a gate staff account, which logs in at
+// /api/admin/login and gets a staff token.
+func AdminCreateStaff(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			FullName string `json:"full_name" binding:"required"`
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required,min=6"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hashedPassword, err := utils.HashPassword(input.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+
+		staff := models.Admin{
+			FullName:  strings.TrimSpace(input.FullName),
+			Email:     input.Email,
+			Password:  hashedPassword,
+			Role:      roleStaff,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.Create(&staff).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create staff account"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Staff account created", "account": staff})
+	}
+}
+
+// AdminSetAccountRole — Admin: promote a staff account to admin or demote an
+// admin to staff. Admins cannot change their own role, so one is always left.
+// The new role applies from the account's next login.
+func AdminSetAccountRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || (req.Role != roleAdmin && req.Role != roleStaff) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `Role must be "admin" or "staff"`})
+			return
+		}
+
+		var account models.Admin
+		if err := db.First(&account, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+			return
+		}
+		if account.ID == c.GetUint("userId") {
+			c.JSON(http.StatusConflict, gin.H{"error": "You cannot change your own role"})
+			return
+		}
+
+		if err := db.Model(&account).Updates(map[string]interface{}{
+			"role":       req.Role,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Role updated", "account": account})
+	}
+}
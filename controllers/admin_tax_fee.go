@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type taxFeePayload struct {
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	TheatreID *uint   `json:"theatre_id"`
+	AppliesTo string  `json:"applies_to"`
+	RateType  string  `json:"rate_type"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Active    *bool   `json:"active"`
+	Position  int     `json:"position"`
+}
+
+// apply copies the payload onto a rule and validates the result.
+func (p *taxFeePayload) apply(rule *models.TaxFee) error {
+	rule.Name = strings.TrimSpace(p.Name)
+	rule.Kind = p.Kind
+	rule.TheatreID = p.TheatreID
+	rule.AppliesTo = p.AppliesTo
+	if rule.AppliesTo == "" {
+		rule.AppliesTo = "tickets"
+	}
+	rule.RateType = p.RateType
+	rule.Rate = p.Rate
+	rule.Inclusive = p.Inclusive
+	if p.Active != nil {
+		rule.Active = *p.Active
+	}
+	rule.Position = p.Position
+	return utils.ValidateTaxFee(rule)
+}
+
+// AdminListTaxFees — Admin: all taxes and fees in bill order (?theatre_id= for the
+// ones a theatre charges)
+func AdminListTaxFees(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Order("position, id")
+		if theatreID := c.Query("theatre_id"); theatreID != "" {
+			query = query.Where("theatre_id IS NULL OR theatre_id = ?", theatreID)
+		}
+
+		var rules []models.TaxFee
+		if err := query.Find(&rules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch taxes and fees"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tax_fees": rules})
+	}
+}
+
+// AdminCreateTaxFee — Admin: add a tax or fee
+func AdminCreateTaxFee(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload taxFeePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax or fee data"})
+			return
+		}
+
+		rule := models.TaxFee{Active: true}
+		if err := payload.apply(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tax or fee"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Tax or fee created successfully", "tax_fee": rule})
+	}
+}
+
+// AdminUpdateTaxFee — Admin: edit a tax or fee. Bookings already made keep the
+// lines they were charged.
+func AdminUpdateTaxFee(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload taxFeePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax or fee data"})
+			return
+		}
+
+		var rule models.TaxFee
+		if err := db.First(&rule, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax or fee not found"})
+			return
+		}
+		if err := payload.apply(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Save(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tax or fee"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Tax or fee updated successfully", "tax_fee": rule})
+	}
+}
+
+// AdminDeleteTaxFee — Admin: remove a tax or fee
+func AdminDeleteTaxFee(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.TaxFee{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tax or fee"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax or fee not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Tax or fee deleted successfully"})
+	}
+}
@@ -224,8 +224,12 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 
-	// Create JWT with role=admin
-	token, err := utils.CreateToken(uint(admin.ID), "admin")
+	// Create JWT with role=admin, or role=staff for gate staff accounts
+	role := roleAdmin
+	if admin.Role == roleStaff {
+		role = roleStaff
+	}
+	token, err := utils.CreateToken(uint(admin.ID), role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
 		return
@@ -258,7 +262,7 @@ func AdminLogin(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":       "success",
-		"role":         "admin",
+		"role":         role,
 		"access_token": token,
 		"admin": gin.H{
 			"id":    admin.ID,
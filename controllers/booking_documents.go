@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadDocumentBooking fetches a booking with everything printed on its ticket and
+// invoice. A userID of 0 loads any booking, for admins.
+func loadDocumentBooking(db *gorm.DB, id string, userID uint) (*models.Booking, error) {
+	query := db.Preload("User").
+		Preload("Seats", "active = ?", true).
+		Preload("Invoice", "food_order_id IS NULL").
+		Preload("Discounts").
+		Preload("Charges").
+		Preload("FoodOrders", "status <> ?", "cancelled").
+		Preload("FoodOrders.Items").
+		Preload("Show.Movie").
+		Preload("Show.Screen.Theatre").
+		Where("id = ?", id)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var booking models.Booking
+	if err := query.First(&booking).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func sendPDF(c *gin.Context, filename string, pdf []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// sendTicketPDF writes the printable ticket of a confirmed booking.
+func sendTicketPDF(c *gin.Context, booking *models.Booking) {
+	if booking.Status != "confirmed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tickets are only issued for confirmed bookings"})
+		return
+	}
+
+	token, err := issueTicket(booking)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+	qr, err := utils.TicketQRCode(token, ticketQRSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render ticket QR code"})
+		return
+	}
+
+	pdf, err := utils.TicketPDF(booking, qr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render ticket"})
+		return
+	}
+	sendPDF(c, fmt.Sprintf("ticket-%d.pdf", booking.ID), pdf)
+}
+
+// sendInvoicePDF writes the tax invoice of a booking, issuing it first for
+// confirmed bookings that were paid before invoicing existed.
+func sendInvoicePDF(c *gin.Context, db *gorm.DB, booking *models.Booking) {
+	if booking.Invoice == nil {
+		if booking.Status != "confirmed" {
+			c.JSON(http.StatusConflict, gin.H{"error": "An invoice is issued once the booking is paid"})
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			invoice, err := utils.IssueInvoice(tx, booking)
+			booking.Invoice = invoice
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+			return
+		}
+	}
+
+	pdf, err := utils.InvoicePDF(booking, booking.Invoice)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+		return
+	}
+	sendPDF(c, booking.Invoice.Number+".pdf", pdf)
+}
+
+// GetBookingTicketPDF — User: download the printable ticket of their booking.
+func GetBookingTicketPDF(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, err := loadDocumentBooking(db, c.Param("id"), c.GetUint("userId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		sendTicketPDF(c, booking)
+	}
+}
+
+// GetBookingInvoicePDF — User: download the tax invoice of their booking.
+func GetBookingInvoicePDF(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, err := loadDocumentBooking(db, c.Param("id"), c.GetUint("userId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		sendInvoicePDF(c, db, booking)
+	}
+}
+
+// AdminGetBookingTicketPDF — Admin: download the printable ticket of any booking.
+func AdminGetBookingTicketPDF(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, err := loadDocumentBooking(db, c.Param("id"), 0)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		sendTicketPDF(c, booking)
+	}
+}
+
+// AdminGetBookingInvoicePDF — Admin: download the tax invoice of any booking.
+func AdminGetBookingInvoicePDF(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, err := loadDocumentBooking(db, c.Param("id"), 0)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		sendInvoicePDF(c, db, booking)
+	}
+}
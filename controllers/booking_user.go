@@ -2,60 +2,75 @@ package controllers
 
 import (
 	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var seatRows = []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
-
-const maxColsPerRow = 12
-
-func isSeatCodeValid(seatCode string, seatsTotal int) bool {
-	if len(seatCode) < 2 {
-		return false
-	}
-
-	rowLetter := strings.ToUpper(seatCode[:1])
-	colStr := seatCode[1:]
-
-	col, err := strconv.Atoi(colStr)
-	if err != nil || col <= 0 || col > maxColsPerRow {
-		return false
-	}
-
-	rowIndex := -1
-	for i, r := range seatRows {
-		if r == rowLetter {
-			rowIndex = i
-			break
+// activeBookingClause matches bookings that still occupy their seats and parking:
+// confirmed bookings, and pending bookings and group reservations whose hold has
+// not run out yet. It expects the current time as its only argument.
+const activeBookingClause = "(bookings.status = 'confirmed' OR (bookings.status IN ('pending', 'reserved') AND (bookings.expires_at IS NULL OR bookings.expires_at > ?)))"
+
+// normalizeSeatCodes upper-cases the requested seat codes so "a1" and "A1" name the
+// same seat, and reports false if the same seat is requested twice.
+func normalizeSeatCodes(codes []string) ([]string, bool) {
+	seen := make(map[string]bool, len(codes))
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if seen[code] {
+			return nil, false
 		}
+		seen[code] = true
+		normalized = append(normalized, code)
 	}
+	return normalized, true
+}
 
-	if rowIndex == -1 {
-		return false
+// lockBookingShow locks the show of a booking ahead of the booking itself, the
+// order bookings and exchanges take their locks in, for paths that release the
+// booking's seats.
+func lockBookingShow(tx *gorm.DB, bookingID interface{}) error {
+	var showIDs []uint
+	if err := tx.Model(&models.Booking{}).Where("id = ?", bookingID).Pluck("show_id", &showIDs).Error; err != nil {
+		return err
 	}
+	if len(showIDs) == 0 {
+		return nil // the caller reports the missing booking
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, showIDs[0]).Error
+}
 
-	absoluteSeatIndex := (rowIndex * maxColsPerRow) + col
-
-	return absoluteSeatIndex <= seatsTotal
+// bookingSeatCodes returns the seat codes of a booking, for seat map events.
+// Seats it gave up in an exchange or had refunded are no longer its own.
+func bookingSeatCodes(db *gorm.DB, bookingID uint) []string {
+	var codes []string
+	db.Model(&models.BookingSeat{}).Where("booking_id = ? AND exchanged_at IS NULL AND refunded_at IS NULL", bookingID).Pluck("seat_code", &codes)
+	return codes
 }
 
 func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			ShowID        uint     `json:"show_id"`
-			SeatCodes     []string `json:"seat_codes"`
-			PaymentMethod string   `json:"payment_method"`
-			HasParking    bool     `json:"has_parking"`
-			VehicleType   string   `json:"vehicle_type"`
+			ShowID        uint             `json:"show_id"`
+			SeatCodes     []string         `json:"seat_codes"`
+			PaymentMethod string           `json:"payment_method"`
+			HasParking    bool             `json:"has_parking"`
+			VehicleType   string           `json:"vehicle_type"`
+			PromoCode     string           `json:"promo_code"`
+			RedeemPoints  int              `json:"redeem_points"` // loyalty points to pay part of the seats with
+			Food          []utils.FoodLine `json:"food"`          // concessions to pre-order
 		}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
+		if err := c.ShouldBindJSON(&req); err != nil || req.RedeemPoints < 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking data"})
 			return
 		}
@@ -89,40 +104,37 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 				return
 			}
 
-			switch strings.ToLower(req.VehicleType) {
-			case "car":
-				parkingFee = show.Screen.Theatre.CarParkingFee
-				vehicleType = "Car"
-				capacity = show.Screen.Theatre.CarParkingCapacity
-			case "bike":
-				parkingFee = show.Screen.Theatre.BikeParkingFee
-				vehicleType = "Bike"
-				capacity = show.Screen.Theatre.BikeParkingCapacity
-			default:
+			var ok bool
+			if vehicleType, parkingFee, capacity, ok = parkingVehicle(&show.Screen.Theatre, req.VehicleType); !ok {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle type for parking"})
 				return
 			}
+		} else if req.VehicleType != "" {
+			req.VehicleType = ""
+		}
 
-			var currentBookedParking int64
-			err := db.Model(&models.Booking{}).Where("show_id = ? AND vehicle_type = ? AND has_parking = ? AND status IN (?, ?)",
-				show.ID, vehicleType, true, "confirmed", "pending").Count(&currentBookedParking).Error
-
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Parking availability"})
-				return
-			}
+		seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate seat codes in request"})
+			return
+		}
+		req.SeatCodes = seatCodes
 
-			if capacity > 0 && int(currentBookedParking) >= capacity {
-				c.JSON(http.StatusConflict, gin.H{"error": "Parking for " + vehicleType + " is full for this show"})
-				return
-			}
+		seatLayout, err := loadSeatLayout(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+			return
+		}
+		validSeats := bookableSeats(seatLayout)
 
-		} else if req.VehicleType != "" {
-			req.VehicleType = ""
+		prices, err := loadSeatPrices(db, &show, seatLayout)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat prices"})
+			return
 		}
 
 		for _, code := range req.SeatCodes {
-			if !isSeatCodeValid(code, show.SeatsTotal) {
+			if !validSeats[code] {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seat code: " + code + ". This seat is not part of the screen layout."})
 				return
 			}
@@ -136,12 +148,41 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 			}
 		}()
 
-		// Fetch already booked seats for this show, only considering confirmed or pending bookings
+		// Lock the show row so concurrent bookings for the same show are serialised
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, show.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show for booking"})
+			return
+		}
+
+		// Free seats whose hold lapsed before the sweeper got to them
+		lapsed, offers, err := releaseLapsedHolds(tx, show.ID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release expired seat holds"})
+			return
+		}
+
+		// Parking is counted under the show lock too, so the last slot goes to one booking
+		if req.HasParking {
+			var currentBookedParking int64
+			if err := tx.Model(&models.Booking{}).Where("show_id = ? AND vehicle_type = ? AND has_parking = ?", show.ID, vehicleType, true).
+				Where(activeBookingClause, time.Now()).Count(&currentBookedParking).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Parking availability"})
+				return
+			}
+			if capacity > 0 && int(currentBookedParking) >= capacity {
+				tx.Rollback()
+				c.JSON(http.StatusConflict, gin.H{"error": "Parking for " + vehicleType + " is full for this show"})
+				return
+			}
+		}
+
+		// Fetch seats already taken for this show by confirmed bookings and live holds
 		var bookedSeats []models.BookingSeat
-		if err := tx.Table("booking_seats").
-			Select("booking_seats.seat_code").
-			Joins("JOIN bookings ON bookings.id = booking_seats.booking_id").
-			Where("booking_seats.show_id = ? AND bookings.status IN (?, ?)", show.ID, "confirmed", "pending"). // Filter by confirmed or pending status
+		if err := tx.Where("show_id = ? AND active = ?", show.ID, true).
+			Select("seat_code").
 			Find(&bookedSeats).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch currently booked seats"})
@@ -156,26 +197,72 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 
 		// Validate seat codes against currently booked seats
 		var bookingSeats []models.BookingSeat
+		var conflicts []string
+		var seatSubtotal float64
 		for _, code := range req.SeatCodes {
 			if bookedMap[code] {
+				conflicts = append(conflicts, code)
+				continue
+			}
+
+			bookingSeats = append(bookingSeats, prices[code].bookingSeat(show.ID, code, time.Now()))
+			seatSubtotal += prices[code].Price
+		}
+
+		if len(conflicts) > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error":             "Seats already booked by another active booking: " + strings.Join(conflicts, ", "),
+				"conflicting_seats": conflicts,
+			})
+			return
+		}
+
+		// Apply the promo code to the seat subtotal
+		var promo *models.Promotion
+		var discount float64
+		if strings.TrimSpace(req.PromoCode) != "" {
+			promo, discount, err = utils.ApplyPromotion(tx, req.PromoCode, userID, &show, show.Screen.TheatreID, len(req.SeatCodes), seatSubtotal, time.Now())
+			if err != nil {
 				tx.Rollback()
-				c.JSON(http.StatusConflict, gin.H{"error": "Seat " + code + " already booked by another active booking."})
+				if errors.Is(err, utils.ErrPromotionNotApplicable) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check promo code"})
 				return
 			}
+		}
 
-			bookingSeats = append(bookingSeats, models.BookingSeat{
-				ShowID:    show.ID,
-				SeatCode:  code,
-				Price:     show.Price,
-				CreatedAt: time.Now(),
-			})
+		// Loyalty points pay for seats, never for parking
+		var pointsValue float64
+		if req.RedeemPoints > 0 {
+			pointsValue = utils.RoundMoney(float64(req.RedeemPoints) * utils.LoyaltyPointValue())
+			if payable := utils.RoundMoney(seatSubtotal - discount); pointsValue > payable {
+				tx.Rollback()
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Points can pay for at most the seats (%.2f)", payable)})
+				return
+			}
+		}
+
+		// Taxes and fees of the theatre, on the discounted seats and the parking
+		charges, err := utils.CalculateCharges(tx, &show, utils.ChargeBase{
+			Tickets: utils.RoundMoney(seatSubtotal - discount),
+			Parking: parkingFee,
+			Seats:   len(req.SeatCodes),
+		})
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load taxes and fees"})
+			return
 		}
 
-		// Calculate total: Seat Subtotal + Parking Fee
-		seatSubtotal := float64(len(req.SeatCodes)) * show.Price
-		totalAmount := seatSubtotal + parkingFee
+		// Calculate total: Seat Subtotal (each seat at its category price) - Discount - Points + Parking Fee
+		// + the taxes and fees not included in those prices
+		totalAmount := utils.RoundMoney(seatSubtotal - discount - pointsValue + parkingFee + charges.Added())
 
-		// Create booking
+		// Create booking, holding the seats until the customer pays
+		expiresAt := time.Now().Add(utils.SeatHoldTTL())
 		booking := models.Booking{
 			UserID:        userID,
 			ShowID:        show.ID,
@@ -187,6 +274,12 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 			HasParking:    req.HasParking,
 			VehicleType:   vehicleType,
 			ParkingFee:    parkingFee,
+			Discount:      discount,
+			PointsUsed:    req.RedeemPoints,
+			PointsValue:   pointsValue,
+			Fees:          charges.Fees,
+			Taxes:         charges.Taxes,
+			ExpiresAt:     &expiresAt,
 		}
 
 		if err := tx.Create(&booking).Error; err != nil {
@@ -195,6 +288,48 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if err := utils.SaveCharges(tx, booking.ID, charges); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save taxes and fees"})
+			return
+		}
+
+		// Food is paid for with the tickets
+		var foodOrder *models.FoodOrder
+		if len(req.Food) > 0 {
+			foodOrder, err = utils.PlaceFoodOrder(tx, &booking, show.Screen.TheatreID, req.Food, "pending")
+			if err != nil {
+				tx.Rollback()
+				if errors.Is(err, utils.ErrFoodUnavailable) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place food order"})
+				return
+			}
+			totalAmount = utils.RoundMoney(totalAmount + foodOrder.Total)
+			booking.TotalAmount, booking.FoodAmount = totalAmount, foodOrder.Total
+			if err := tx.Model(&booking).Updates(map[string]interface{}{"total_amount": totalAmount, "food_amount": foodOrder.Total}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save food order"})
+				return
+			}
+		}
+
+		if promo != nil {
+			if err := tx.Create(&models.BookingDiscount{
+				BookingID:   booking.ID,
+				PromotionID: &promo.ID,
+				Code:        promo.Code,
+				Description: promo.Description,
+				Amount:      discount,
+			}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save discount"})
+				return
+			}
+		}
+
 		// Assign booking ID to seats
 		for i := range bookingSeats {
 			bookingSeats[i].BookingID = booking.ID
@@ -202,11 +337,63 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 
 		if err := tx.Create(&bookingSeats).Error; err != nil {
 			tx.Rollback()
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				// The show lock makes this unreachable in practice; the unique index is the backstop
+				c.JSON(http.StatusConflict, gin.H{
+					"error":             "Seats already booked by another active booking: " + strings.Join(req.SeatCodes, ", "),
+					"conflicting_seats": req.SeatCodes,
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save booking seats"})
 			return
 		}
 
-		tx.Commit()
+		if req.RedeemPoints > 0 {
+			if err := utils.RedeemLoyaltyPoints(tx, userID, booking.ID, req.RedeemPoints); err != nil {
+				tx.Rollback()
+				if errors.Is(err, utils.ErrInsufficientPoints) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Not enough loyalty points: " + err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem loyalty points"})
+				return
+			}
+		}
+
+		// Nothing left to pay online: the booking is confirmed straight away,
+		// as is one paid from the wallet
+		paidInFull := totalAmount == 0
+		if paidInFull {
+			if err := utils.ConfirmPaidBooking(tx, &booking); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm booking"})
+				return
+			}
+		} else if req.PaymentMethod == utils.WalletGateway {
+			if _, err := payFromWallet(tx, &booking, nil); err != nil {
+				tx.Rollback()
+				if errors.Is(err, utils.ErrInsufficientBalance) {
+					c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance: " + err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pay from wallet"})
+				return
+			}
+			paidInFull = true
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit booking"})
+			return
+		}
+		utils.SeatEvents.Publish(show.ID, "released", lapsed)
+		publishWaitlistOffers(offers)
+		if paidInFull {
+			utils.SeatEvents.Publish(show.ID, "booked", req.SeatCodes)
+		} else {
+			utils.SeatEvents.Publish(show.ID, "held", req.SeatCodes)
+		}
 
 		// Fetch the booking with related fields
 		var fullBooking models.Booking
@@ -215,6 +402,9 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 			Preload("Show.Movie").
 			Preload("Show.Screen.Theatre").
 			Preload("Seats").
+			Preload("Discounts").
+			Preload("Charges").
+			Preload("FoodOrders.Items").
 			First(&fullBooking, booking.ID).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booking details"})
 			return
@@ -226,7 +416,15 @@ func CreateBooking(db *gorm.DB) gin.HandlerFunc {
 			"seats":         bookingSeats,
 			"Total_Amount":  totalAmount,
 			"seat_subtotal": seatSubtotal,
+			"discount":      discount,
+			"points_used":   req.RedeemPoints,
+			"points_value":  pointsValue,
 			"parking_fee":   parkingFee,
+			"fees":          charges.Fees,
+			"taxes":         charges.Taxes,
+			"charges":       charges.Lines,
+			"food_order":    foodOrder,
+			"expires_at":    expiresAt,
 		})
 	}
 }
@@ -237,7 +435,7 @@ func GetBookingDetailsUser(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 
 		var booking models.Booking
-		if err := db.Preload("Seats").Preload("Show.Movie").First(&booking, id).Error; err != nil {
+		if err := db.Preload("Seats", "exchanged_at IS NULL").Preload("Discounts").Preload("Charges").Preload("Show.Movie").First(&booking, id).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
 		}
@@ -265,3 +463,239 @@ func GetUserBookings(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, bookings)
 	}
 }
+
+// quoteCancellation applies the refund policy to a booking. Only paid bookings
+// get money back; unpaid holds are simply released. Food already collected at the
+// counter is not given back, nor is anything an admin has already refunded on the
+// payment; callers refunding the quote hold the payment row lock.
+func quoteCancellation(db *gorm.DB, booking *models.Booking, now time.Time) (utils.RefundQuote, error) {
+	if booking.Status != "confirmed" || booking.Payment == nil || !utils.IsPaymentCaptured(booking.Payment.Status) {
+		return utils.RefundQuote{}, nil
+	}
+	food, err := utils.UncollectedFoodAmount(db, booking.ID)
+	if err != nil {
+		return utils.RefundQuote{}, err
+	}
+	_, refundable, err := paymentRefundTotals(db, booking.Payment)
+	if err != nil {
+		return utils.RefundQuote{}, err
+	}
+	quote := utils.LoadRefundPolicy().Quote(booking.Show.StartTime, now, booking.TotalAmount-booking.ParkingFee-booking.FoodAmount, booking.ParkingFee, food)
+	return quote.Capped(refundable), nil
+}
+
+// GetCancellationQuote tells the customer what cancelling their booking now would refund.
+func GetCancellationQuote(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var booking models.Booking
+		if err := db.Preload("Payment").Preload("Show").
+			Where("id = ? AND user_id = ?", c.Param("id"), userID).
+			First(&booking).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		now := time.Now()
+		quote, err := quoteCancellation(db, &booking, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to quote the refund"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"booking_id":  booking.ID,
+			"cancellable": (booking.Status == "pending" || booking.Status == "confirmed") && booking.Show.StartTime.After(now) && !transferredBooking(db, booking.ID),
+			"refund":      quote,
+		})
+	}
+}
+
+// CancelBooking lets a customer cancel their own booking. The seats are released,
+// the show's seat count restored and any refund due under the policy recorded
+// against the payment, all in one transaction. Refunds go to the customer's
+// wallet unless they ask for them on the original means of payment.
+func CancelBooking(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			RefundTo string `json:"refund_to"` // "wallet" (default) or "source"
+		}
+		_ = c.ShouldBindJSON(&req)
+		destination, ok := refundDestination(req.RefundTo)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `Refunds go to "wallet" or "source"`})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		if err := lockBookingShow(tx, c.Param("id")); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show"})
+			return
+		}
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", c.Param("id"), userID).
+			First(&booking).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if err := tx.Preload("Payment", func(db *gorm.DB) *gorm.DB {
+			// locked while the refund ledger is checked, as for admin refunds
+			return db.Clauses(clause.Locking{Strength: "UPDATE"})
+		}).Preload("Show").First(&booking, booking.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking"})
+			return
+		}
+
+		if booking.Status != "pending" && booking.Status != "confirmed" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + booking.Status + " booking cannot be cancelled"})
+			return
+		}
+		if transferredBooking(tx, booking.ID) {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Transferred bookings cannot be cancelled"})
+			return
+		}
+		if booking.Payment != nil && booking.Payment.Status == utils.PaymentCapturing {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "The payment for this booking is being captured, try again shortly"})
+			return
+		}
+
+		now := time.Now()
+		if !booking.Show.StartTime.After(now) {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "The show has already started"})
+			return
+		}
+
+		quote, err := quoteCancellation(tx, &booking, now)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to quote the refund"})
+			return
+		}
+		wasConfirmed := booking.Status == "confirmed"
+		releasedSeats := bookingSeatCodes(tx, booking.ID)
+
+		if err := tx.Model(&booking).Updates(map[string]interface{}{
+			"status":     "cancelled",
+			"updated_at": now,
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+			return
+		}
+
+		if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ?", booking.ID).Update("active", false).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release seats"})
+			return
+		}
+		if err := utils.CancelFoodOrders(tx, []uint{booking.ID}); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel food orders"})
+			return
+		}
+		offers, err := offerWaitlistSeats(tx, booking.ShowID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to offer seats to the waitlist"})
+			return
+		}
+
+		if wasConfirmed {
+			if err := tx.Model(&models.Show{}).
+				Where("id = ? AND seats_booked >= ?", booking.ShowID, booking.SeatsCount).
+				Update("seats_booked", gorm.Expr("seats_booked - ?", booking.SeatsCount)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seats count"})
+				return
+			}
+		}
+
+		// Points that paid for the seats come back like money would; points the
+		// booking earned are taken back
+		pointsPercent := 100.0
+		if wasConfirmed {
+			pointsPercent = utils.LoadRefundPolicy().Quote(booking.Show.StartTime, now, 0, 0, 0).SeatPercent
+		}
+		if err := utils.RestoreLoyaltyPoints(tx, &booking, int(float64(booking.PointsUsed)*pointsPercent/100), "booking cancelled"); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore loyalty points"})
+			return
+		}
+		if wasConfirmed {
+			if err := utils.ReverseLoyaltyPoints(tx, &booking, booking.TotalAmount-booking.ParkingFee-booking.FoodAmount, "booking cancelled"); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update loyalty points"})
+				return
+			}
+		}
+
+		var refund *models.Refund
+		if quote.Total > 0 {
+			refund = &models.Refund{
+				PaymentID:     booking.Payment.ID,
+				BookingID:     booking.ID,
+				Amount:        quote.Total,
+				SeatAmount:    quote.SeatRefund,
+				ParkingAmount: quote.ParkingRefund,
+				FoodAmount:    quote.FoodRefund,
+				Type:          "cancellation",
+				Reason:        "customer_cancellation",
+			}
+			if err := refundPayment(tx, booking.Payment, refund, destination); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refund the payment, please try again"})
+				return
+			}
+			if err := tx.Create(refund).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record refund"})
+				return
+			}
+
+			if err := markRefunded(tx, booking.Payment, "customer_cancellation"); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
+				return
+			}
+		} else if booking.Payment != nil && (booking.Payment.Status == utils.PaymentInitiated || booking.Payment.Status == utils.PaymentAuthorized) {
+			// Not captured yet: the attempt is abandoned with the booking
+			if err := utils.TransitionPayment(tx, booking.Payment, utils.PaymentCancelled, "booking cancelled", nil); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
+				return
+			}
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		utils.SeatEvents.Publish(booking.ShowID, "released", releasedSeats)
+		publishWaitlistOffers(offers)
+		settleRefund(db, refund)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "Booking cancelled successfully",
+			"booking_id":    booking.ID,
+			"status":        "cancelled",
+			"refund":        quote,
+			"refund_record": refund,
+		})
+	}
+}
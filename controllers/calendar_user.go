@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// calendarStatuses are the bookings exported to calendars; cancelled ones are
+// kept so subscribed calendars remove the event.
+var calendarStatuses = []string{"confirmed", "cancelled"}
+
+// bookingEvent turns a booking loaded with Seats and Show.Movie / Show.Screen.Theatre
+// into a calendar event.
+func bookingEvent(booking *models.Booking) utils.CalendarEvent {
+	show := &booking.Show
+
+	var seats []string
+	for _, seat := range booking.Seats {
+		seats = append(seats, seat.SeatCode)
+	}
+	description := fmt.Sprintf("Booking #%d", booking.ID)
+	if len(seats) > 0 {
+		description += "\nSeats: " + strings.Join(seats, ", ")
+	}
+	if show.Screen.Name != "" {
+		description += "\nScreen: " + show.Screen.Name
+	}
+	if booking.HasParking {
+		description += "\nParking: " + booking.VehicleType
+	}
+
+	location := show.Screen.Theatre.Name
+	if show.Screen.Theatre.Location != "" {
+		location += ", " + show.Screen.Theatre.Location
+	}
+
+	return utils.CalendarEvent{
+		UID:         fmt.Sprintf("booking-%d@cineverse", booking.ID),
+		Start:       show.StartTime,
+		End:         showEndTime(show),
+		Summary:     show.Movie.Title,
+		Location:    location,
+		Description: description,
+		Cancelled:   booking.Status == "cancelled",
+		Updated:     booking.UpdatedAt,
+	}
+}
+
+// userCalendar renders the calendar of all of a user's bookings.
+func userCalendar(db *gorm.DB, userID uint) ([]byte, error) {
+	var bookings []models.Booking
+	if err := db.Preload("Seats", "active = ?", true).
+		Preload("Show.Movie").
+		Preload("Show.Screen.Theatre").
+		Where("user_id = ? AND status IN ?", userID, calendarStatuses).
+		Order("created_at desc").
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	events := make([]utils.CalendarEvent, 0, len(bookings))
+	for i := range bookings {
+		events = append(events, bookingEvent(&bookings[i]))
+	}
+	return utils.BuildCalendar("CineVerse bookings", events), nil
+}
+
+func sendCalendar(c *gin.Context, filename string, ics []byte) {
+	if filename != "" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", ics)
+}
+
+// calendarFeedURL is the absolute URL a calendar app subscribes to.
+func calendarFeedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api/calendar/" + token + ".ics"
+}
+
+// GetUserBookingsCalendar — User: download all their bookings as an .ics file.
+func GetUserBookingsCalendar(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ics, err := userCalendar(db, c.GetUint("userId"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookings"})
+			return
+		}
+		sendCalendar(c, "bookings.ics", ics)
+	}
+}
+
+// GetBookingCalendar — User: download one booking as an .ics file.
+func GetBookingCalendar(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var booking models.Booking
+		if err := db.Preload("Seats", "active = ?", true).
+			Preload("Show.Movie").
+			Preload("Show.Screen.Theatre").
+			Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&booking).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		ics := utils.BuildCalendar(booking.Show.Movie.Title, []utils.CalendarEvent{bookingEvent(&booking)})
+		sendCalendar(c, fmt.Sprintf("booking-%d.ics", booking.ID), ics)
+	}
+}
+
+// CreateCalendarFeed — User: issue a private calendar feed URL. Any previous URL
+// stops working; the token is only shown in this response.
+func CreateCalendarFeed(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		token, hashed, err := utils.GenerateRefreshToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate feed token"})
+			return
+		}
+
+		feed := models.CalendarFeed{UserID: userID}
+		if err := db.Where("user_id = ?", userID).
+			Assign(map[string]interface{}{"token_hash": hashed, "last_fetched_at": nil}).
+			FirstOrCreate(&feed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar feed"})
+			return
+		}
+
+		url := calendarFeedURL(c, token)
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Calendar feed created",
+			"feed_url":   url,
+			"webcal_url": "webcal://" + strings.SplitN(url, "://", 2)[1],
+		})
+	}
+}
+
+// DeleteCalendarFeed — User: revoke their calendar feed URL.
+func DeleteCalendarFeed(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("user_id = ?", c.GetUint("userId")).Delete(&models.CalendarFeed{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke calendar feed"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No calendar feed to revoke"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked"})
+	}
+}
+
+// CalendarFeed — Public: the subscribable calendar behind a feed URL. It is built
+// on every fetch, so confirmations and cancellations show up on the next refresh.
+func CalendarFeed(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+		var feed models.CalendarFeed
+		if err := db.Where("token_hash = ?", utils.CalendarFeedTokenHash(token)).First(&feed).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+
+		ics, err := userCalendar(db, feed.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookings"})
+			return
+		}
+		db.Model(&feed).Update("last_fetched_at", time.Now())
+
+		sendCalendar(c, "", ics)
+	}
+}
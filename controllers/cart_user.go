@@ -0,0 +1,661 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxCartShows = 5
+
+// openCart returns the user's open cart, creating an empty one if asked to.
+func openCart(db *gorm.DB, userID uint, create bool) (*models.Cart, error) {
+	var cart models.Cart
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Show.Movie").Preload("Items.Show.Screen.Theatre").
+		Where("user_id = ? AND status = ?", userID, "open").First(&cart).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && create {
+		cart = models.Cart{UserID: userID, Status: "open"}
+		err = db.Create(&cart).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// parkingVehicle resolves a requested vehicle type to the name stored on bookings
+// and the theatre's fee and capacity for it.
+func parkingVehicle(theatre *models.Theatre, requested string) (string, float64, int, bool) {
+	switch strings.ToLower(requested) {
+	case "car":
+		return "Car", theatre.CarParkingFee, theatre.CarParkingCapacity, true
+	case "bike":
+		return "Bike", theatre.BikeParkingFee, theatre.BikeParkingCapacity, true
+	}
+	return "", 0, 0, false
+}
+
+// GetCart — User: their open cart with the shows and seats in it.
+func GetCart(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cart, err := openCart(db, c.GetUint("userId"), true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+			return
+		}
+		c.JSON(http.StatusOK, cart)
+	}
+}
+
+// AddCartItem — User: put seats of a show in their cart. Nothing is held until
+// checkout; each show can be in the cart once.
+func AddCartItem(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ShowID      uint     `json:"show_id"`
+			SeatCodes   []string `json:"seat_codes"`
+			HasParking  bool     `json:"has_parking"`
+			VehicleType string   `json:"vehicle_type"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.SeatCodes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart item"})
+			return
+		}
+		seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate seat codes in request"})
+			return
+		}
+
+		var show models.Show
+		if err := db.Preload("Screen.Theatre").First(&show, req.ShowID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+		if !show.StartTime.After(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This show has already started"})
+			return
+		}
+
+		vehicleType := ""
+		if req.HasParking {
+			if !show.Screen.Theatre.ParkingAvailable {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parking not available at this theatre"})
+				return
+			}
+			if vehicleType, _, _, ok = parkingVehicle(&show.Screen.Theatre, req.VehicleType); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle type for parking"})
+				return
+			}
+		}
+
+		seatLayout, err := loadSeatLayout(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+			return
+		}
+		validSeats := bookableSeats(seatLayout)
+		for _, code := range seatCodes {
+			if !validSeats[code] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seat code: " + code + ". This seat is not part of the screen layout."})
+				return
+			}
+		}
+
+		cart, err := openCart(db, c.GetUint("userId"), true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+			return
+		}
+		if len(cart.Items) >= maxCartShows {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A cart holds at most %d shows", maxCartShows)})
+			return
+		}
+		for _, item := range cart.Items {
+			if item.ShowID == show.ID {
+				c.JSON(http.StatusConflict, gin.H{"error": "This show is already in your cart, remove it first to change the seats", "item_id": item.ID})
+				return
+			}
+		}
+
+		item := models.CartItem{
+			CartID:      cart.ID,
+			ShowID:      show.ID,
+			SeatCodes:   seatCodes,
+			HasParking:  req.HasParking,
+			VehicleType: vehicleType,
+		}
+		if err := db.Create(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+			return
+		}
+		item.Show = show
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Added to cart", "item": item})
+	}
+}
+
+// RemoveCartItem — User: take a show out of their open cart.
+func RemoveCartItem(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("id = ? AND cart_id IN (?)", c.Param("id"),
+			db.Model(&models.Cart{}).Select("id").Where("user_id = ? AND status = ?", c.GetUint("userId"), "open")).
+			Delete(&models.CartItem{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove from cart"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Removed from cart"})
+	}
+}
+
+// cartShow is what checkout needs to know about the show of a cart item.
+type cartShow struct {
+	item   models.CartItem
+	show   models.Show
+	prices map[string]seatPrice
+}
+
+// payCart pays for the bookings of a checked-out cart. A wallet pays the whole
+// cart at once and confirms every booking; otherwise each booking gets an
+// initiated payment for its share of the cart total and payCart reports that the
+// caller must call startCartPayment once committed, which creates the one
+// gateway intent they share so the webhook confirms or fails them together. The
+// failed payments of an earlier attempt, in booking order, are reused as
+// retryPayment does.
+func payCart(tx *gorm.DB, cart *models.Cart, bookings []models.Booking, failed []models.Payment, method string) (bool, error) {
+	var total float64
+	for _, booking := range bookings {
+		total += booking.TotalAmount
+	}
+	total = utils.RoundMoney(total)
+	if total == 0 {
+		// nothing to pay, as in CreateBooking
+		for i := range bookings {
+			if err := utils.ConfirmPaidBooking(tx, &bookings[i]); err != nil {
+				return false, err
+			}
+		}
+		cart.Status = "paid"
+		return false, tx.Model(cart).Update("status", "paid").Error
+	}
+
+	// The gateway reference is filled in by startCartPayment
+	gatewayName, ref := utils.WalletGateway, ""
+	viaGateway := method != utils.WalletGateway
+	if !viaGateway {
+		entry, err := utils.MoveFromWallet(tx, cart.UserID, utils.LedgerSales, total, models.LedgerTransaction{
+			Type:      utils.LedgerPayment,
+			Reference: fmt.Sprintf("cart-%d", cart.ID),
+		})
+		if err != nil {
+			return false, err
+		}
+		ref = fmt.Sprintf("ledger:%d", entry.ID)
+	} else {
+		gatewayName = utils.ActivePaymentGateway().Name()
+	}
+
+	for i := range bookings {
+		booking := &bookings[i]
+		var payment *models.Payment
+		if failed != nil {
+			payment = &failed[i]
+			if err := utils.TransitionPayment(tx, payment, utils.PaymentInitiated, fmt.Sprintf("retry, attempt %d", payment.Attempts+1), map[string]interface{}{
+				"amount":      booking.TotalAmount,
+				"method":      method,
+				"gateway":     gatewayName,
+				"provider_tx": ref,
+				"attempts":    gorm.Expr("attempts + 1"),
+			}); err != nil {
+				return false, err
+			}
+		} else {
+			payment = &models.Payment{
+				BookingID:  booking.ID,
+				Amount:     booking.TotalAmount,
+				Method:     method,
+				Gateway:    gatewayName,
+				ProviderTx: ref,
+				Status:     utils.PaymentInitiated,
+				Attempts:   1,
+			}
+			if err := tx.Create(payment).Error; err != nil {
+				return false, err
+			}
+			if err := tx.Create(&models.PaymentTransition{PaymentID: payment.ID, ToStatus: utils.PaymentInitiated, Reason: "cart checkout"}).Error; err != nil {
+				return false, err
+			}
+		}
+
+		if viaGateway {
+			continue
+		}
+		if err := utils.TransitionPayment(tx, payment, utils.PaymentAuthorized, "paid from wallet", nil); err != nil {
+			return false, err
+		}
+		if err := utils.TransitionPayment(tx, payment, utils.PaymentCaptured, "captured", nil); err != nil {
+			return false, err
+		}
+		if err := utils.ConfirmPaidBooking(tx, booking); err != nil {
+			return false, err
+		}
+	}
+
+	status := "checkout"
+	if !viaGateway {
+		status = "paid"
+	}
+	cart.Status, cart.Total, cart.Gateway, cart.ProviderTx = status, total, gatewayName, ref
+	return viaGateway, tx.Model(cart).Updates(map[string]interface{}{
+		"status":      status,
+		"total":       total,
+		"gateway":     gatewayName,
+		"provider_tx": ref,
+	}).Error
+}
+
+// startCartPayment creates the gateway intent for the payments payCart left
+// initiated, after its transaction committed so no show locks wait on the
+// gateway. If the gateway is unavailable the payments fail, and the customer can
+// pay for the cart again while its seats are held.
+func startCartPayment(db *gorm.DB, cart *models.Cart, method string) (*utils.PaymentIntent, error) {
+	gateway := utils.ActivePaymentGateway()
+	intent, intentErr := gateway.CreateIntent(cart.Total, method, fmt.Sprintf("cart-%d", cart.ID))
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var payments []models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("booking_id IN (?) AND status = ? AND provider_tx = ?",
+				tx.Model(&models.Booking{}).Select("id").Where("cart_id = ?", cart.ID), utils.PaymentInitiated, "").
+			Find(&payments).Error; err != nil {
+			return err
+		}
+		if len(payments) == 0 {
+			return errors.New("cart payment changed before the gateway intent was created")
+		}
+		for i := range payments {
+			if intentErr != nil {
+				if err := utils.TransitionPayment(tx, &payments[i], utils.PaymentFailed, "gateway unavailable", nil); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&payments[i]).Update("provider_tx", intent.ID).Error; err != nil {
+				return err
+			}
+		}
+		if intentErr != nil {
+			return nil
+		}
+		return tx.Model(cart).Update("provider_tx", intent.ID).Error
+	})
+	if intentErr != nil {
+		return nil, intentErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// cartCheckedOut is the response to a cart checkout or a retried cart payment.
+func cartCheckedOut(c *gin.Context, db *gorm.DB, cart *models.Cart, intent *utils.PaymentIntent) {
+	if err := db.Preload("Bookings.Seats").Preload("Bookings.Show.Movie").Preload("Bookings.Payment").
+		First(cart, cart.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+		return
+	}
+	if intent == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Cart paid, bookings confirmed", "cart": cart})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Seats held, complete the payment to confirm every booking",
+		"cart":         cart,
+		"intent_id":    intent.ID,
+		"checkout_url": intent.CheckoutURL,
+	})
+}
+
+// CheckoutCart — User: hold the seats of every show in their open cart and pay for
+// them with one payment. The holds are taken together: if any seat is gone no
+// booking is made. A wallet payment confirms the bookings at once; a gateway
+// payment confirms them all from its webhook, or fails them all.
+func CheckoutCart(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			Method string `json:"method"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Method == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method is required"})
+			return
+		}
+
+		cart, err := openCart(db, userID, false)
+		if err != nil || len(cart.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Your cart is empty"})
+			return
+		}
+
+		now := time.Now()
+		shows := make([]cartShow, 0, len(cart.Items))
+		for _, item := range cart.Items {
+			show := item.Show
+			if !show.StartTime.After(now) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A show in your cart has already started", "item_id": item.ID})
+				return
+			}
+			seatLayout, err := loadSeatLayout(db, &show)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+				return
+			}
+			prices, err := loadSeatPrices(db, &show, seatLayout)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat prices"})
+				return
+			}
+			validSeats := bookableSeats(seatLayout)
+			for _, code := range item.SeatCodes {
+				if !validSeats[code] {
+					c.JSON(http.StatusConflict, gin.H{"error": "Seat " + code + " is no longer part of the screen layout", "item_id": item.ID})
+					return
+				}
+			}
+			shows = append(shows, cartShow{item: item, show: show, prices: prices})
+		}
+		// Lock the shows lowest ID first so concurrent checkouts cannot deadlock
+		sort.Slice(shows, func(i, j int) bool { return shows[i].show.ID < shows[j].show.ID })
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		// Re-read the cart under the lock so it is checked out once
+		var current models.Cart
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, cart.ID).Error; err != nil || current.Status != "open" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Cart changed while checking out, please retry"})
+			return
+		}
+
+		conflicts := make(map[uint][]string)
+		lapsed := make(map[uint][]string)
+		var offers []waitlistOffer
+		for _, entry := range shows {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, entry.show.ID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show for booking"})
+				return
+			}
+			released, offered, err := releaseLapsedHolds(tx, entry.show.ID)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release expired seat holds"})
+				return
+			}
+			lapsed[entry.show.ID] = released
+			offers = append(offers, offered...)
+
+			var taken []string
+			if err := tx.Model(&models.BookingSeat{}).Where("show_id = ? AND active = ? AND seat_code IN ?", entry.show.ID, true, entry.item.SeatCodes).
+				Pluck("seat_code", &taken).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch currently booked seats"})
+				return
+			}
+			if len(taken) > 0 {
+				conflicts[entry.show.ID] = taken
+			}
+		}
+		if len(conflicts) > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error":             "Some seats in your cart were booked by someone else; no seats were held",
+				"conflicting_seats": conflicts,
+			})
+			return
+		}
+
+		expiresAt := now.Add(utils.SeatHoldTTL())
+		bookings := make([]models.Booking, 0, len(shows))
+		for _, entry := range shows {
+			show, item := entry.show, entry.item
+
+			var parkingFee float64
+			if item.HasParking {
+				vehicleType, fee, capacity, _ := parkingVehicle(&show.Screen.Theatre, item.VehicleType)
+				var parked int64
+				if err := tx.Model(&models.Booking{}).Where("show_id = ? AND vehicle_type = ? AND has_parking = ?", show.ID, vehicleType, true).
+					Where(activeBookingClause, now).Count(&parked).Error; err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Parking availability"})
+					return
+				}
+				if capacity > 0 && int(parked) >= capacity {
+					tx.Rollback()
+					c.JSON(http.StatusConflict, gin.H{"error": "Parking for " + vehicleType + " is full for " + show.Movie.Title, "item_id": item.ID})
+					return
+				}
+				parkingFee = fee
+			}
+
+			var seats []models.BookingSeat
+			var subtotal float64
+			for _, code := range item.SeatCodes {
+				seats = append(seats, entry.prices[code].bookingSeat(show.ID, code, now))
+				subtotal += entry.prices[code].Price
+			}
+			charges, err := utils.CalculateCharges(tx, &show, utils.ChargeBase{
+				Tickets: utils.RoundMoney(subtotal),
+				Parking: parkingFee,
+				Seats:   len(seats),
+			})
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load taxes and fees"})
+				return
+			}
+
+			booking := models.Booking{
+				UserID:        userID,
+				ShowID:        show.ID,
+				SeatsCount:    len(seats),
+				TotalAmount:   utils.RoundMoney(subtotal + parkingFee + charges.Added()),
+				Status:        "pending",
+				PaymentMethod: req.Method,
+				HasParking:    item.HasParking,
+				VehicleType:   item.VehicleType,
+				ParkingFee:    parkingFee,
+				Fees:          charges.Fees,
+				Taxes:         charges.Taxes,
+				ExpiresAt:     &expiresAt,
+				CartID:        &cart.ID,
+				Seats:         seats,
+			}
+			if err := tx.Create(&booking).Error; err != nil {
+				tx.Rollback()
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					c.JSON(http.StatusConflict, gin.H{"error": "Some seats in your cart were booked by someone else; no seats were held"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+				return
+			}
+			if err := utils.SaveCharges(tx, booking.ID, charges); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save taxes and fees"})
+				return
+			}
+			bookings = append(bookings, booking)
+		}
+
+		if err := tx.Model(&current).Update("expires_at", expiresAt).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+			return
+		}
+		viaGateway, err := payCart(tx, &current, bookings, nil, req.Method)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, utils.ErrInsufficientBalance) {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance: " + err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start the payment, please try again"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit checkout"})
+			return
+		}
+
+		status := "held"
+		if !viaGateway {
+			status = "booked"
+		}
+		for _, entry := range shows {
+			utils.SeatEvents.Publish(entry.show.ID, "released", lapsed[entry.show.ID])
+			utils.SeatEvents.Publish(entry.show.ID, status, entry.item.SeatCodes)
+		}
+		publishWaitlistOffers(offers)
+
+		var intent *utils.PaymentIntent
+		if viaGateway {
+			if intent, err = startCartPayment(db, &current, req.Method); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Seats held, but the payment could not be started; please pay for the cart again", "cart_id": current.ID})
+				return
+			}
+		}
+		cartCheckedOut(c, db, &current, intent)
+	}
+}
+
+// GetUserCart — User: one of their carts, with the bookings made at checkout.
+func GetUserCart(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var cart models.Cart
+		if err := db.Preload("Items.Show.Movie").Preload("Bookings.Seats").Preload("Bookings.Payment").
+			Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&cart).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+			return
+		}
+		c.JSON(http.StatusOK, cart)
+	}
+}
+
+// RetryCartPayment — User: pay again for a checked-out cart whose payment failed,
+// while its seats are still held.
+func RetryCartPayment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Method string `json:"method"` // optional; defaults to the failed attempt's method
+		}
+		_ = c.ShouldBindJSON(&req)
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		var cart models.Cart
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&cart).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+			return
+		}
+		if cart.Status != "checkout" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Only a checked-out cart awaiting payment can be paid again", "status": cart.Status})
+			return
+		}
+
+		var bookings []models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("cart_id = ?", cart.ID).Order("id").Find(&bookings).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart bookings"})
+			return
+		}
+		failed := make([]models.Payment, len(bookings))
+		for i := range bookings {
+			if status, msg := checkPayable(&bookings[i]); status != 0 {
+				tx.Rollback()
+				c.JSON(status, gin.H{"error": msg, "booking_id": bookings[i].ID})
+				return
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_id = ?", bookings[i].ID).First(&failed[i]).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart payment"})
+				return
+			}
+			if failed[i].Status != utils.PaymentFailed {
+				tx.Rollback()
+				c.JSON(http.StatusConflict, gin.H{"error": "Only failed payments can be retried", "status": failed[i].Status})
+				return
+			}
+		}
+		if len(bookings) == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Cart has no bookings to pay for"})
+			return
+		}
+		if req.Method == "" {
+			req.Method = failed[0].Method
+		}
+
+		viaGateway, err := payCart(tx, &cart, bookings, failed, req.Method)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, utils.ErrInsufficientBalance) {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance: " + err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start the payment, please try again"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		var intent *utils.PaymentIntent
+		if viaGateway {
+			if intent, err = startCartPayment(db, &cart, req.Method); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Payment gateway unavailable, please try again", "cart_id": cart.ID})
+				return
+			}
+		} else {
+			for _, booking := range bookings {
+				utils.SeatEvents.Publish(booking.ShowID, "booked", bookingSeatCodes(db, booking.ID))
+			}
+		}
+		cartCheckedOut(c, db, &cart, intent)
+	}
+}
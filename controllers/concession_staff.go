@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cineverse/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetShowFoodOrders — Staff: the paid food orders of a show still to be handed
+// out, with how many of each item the counter has to prepare.
+func GetShowFoodOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var show models.Show
+		if err := db.Preload("Movie").First(&show, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+
+		var orders []models.FoodOrder
+		if err := db.Preload("Items").Where("show_id = ? AND status = ?", show.ID, "confirmed").
+			Order("created_at").Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch food orders"})
+			return
+		}
+
+		type prepItem struct {
+			Name     string `json:"name"`
+			Quantity int    `json:"quantity"`
+		}
+		totals := make(map[string]int)
+		for _, order := range orders {
+			for _, item := range order.Items {
+				totals[item.Name] += item.Quantity
+			}
+		}
+		prepare := make([]prepItem, 0, len(totals))
+		for name, quantity := range totals {
+			prepare = append(prepare, prepItem{name, quantity})
+		}
+		sort.Slice(prepare, func(i, j int) bool { return prepare[i].Name < prepare[j].Name })
+
+		c.JSON(http.StatusOK, gin.H{
+			"show_id":    show.ID,
+			"movie":      show.Movie.Title,
+			"start_time": show.StartTime,
+			"orders":     orders,
+			"prepare":    prepare,
+		})
+	}
+}
+
+// CollectFoodOrder — Staff: hand out a food order by its pickup code. Each order
+// can be collected once.
+func CollectFoodOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			PickupCode string `json:"pickup_code"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.PickupCode) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pickup code is required"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		var order models.FoodOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("pickup_code = ?", strings.ToUpper(strings.TrimSpace(body.PickupCode))).
+			First(&order).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food order not found"})
+			return
+		}
+		switch order.Status {
+		case "collected":
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Food order already collected", "collected_at": order.CollectedAt})
+			return
+		case "confirmed":
+		default:
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + order.Status + " food order cannot be collected"})
+			return
+		}
+
+		now := time.Now()
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"status":       "collected",
+			"collected_at": now,
+			"collected_by": adminID(c),
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to collect food order"})
+			return
+		}
+		if err := tx.Preload("Items").First(&order, order.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load food order"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Collected", "food_order": order})
+	}
+}
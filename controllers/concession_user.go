@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetTheatreConcessions lists what a theatre's counter sells, by category.
+func GetTheatreConcessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var items []models.ConcessionItem
+		if err := db.Where("theatre_id = ? AND active = ?", c.Param("id"), true).
+			Order("category, name").Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch menu"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items})
+	}
+}
+
+// AddBookingFood pre-orders food for one of the customer's bookings before the
+// show. An unpaid booking simply costs more; a paid one is charged for the food
+// straight away through its payment method, as a dearer seat exchange is, and
+// gets a supplementary invoice for it.
+func AddBookingFood(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			Items []utils.FoodLine `json:"items"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid food order"})
+			return
+		}
+
+		var booking models.Booking
+		if err := db.Preload("Show.Screen").Preload("Payment").
+			Where("id = ? AND user_id = ?", c.Param("id"), userID).
+			First(&booking).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if !booking.Show.StartTime.After(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Food can only be pre-ordered before the show starts"})
+			return
+		}
+		switch booking.Status {
+		case "pending":
+			if status, msg := checkPayable(&booking); status != 0 {
+				c.JSON(status, gin.H{"error": msg})
+				return
+			}
+			if booking.Payment != nil && booking.Payment.Status != utils.PaymentFailed {
+				c.JSON(http.StatusConflict, gin.H{"error": "A payment for this booking is in progress, try again once it completes"})
+				return
+			}
+		case "confirmed":
+			if transferredBooking(db, booking.ID) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Food cannot be added to a transferred booking"})
+				return
+			}
+			if booking.Payment == nil || !utils.IsPaymentCaptured(booking.Payment.Status) || booking.Payment.Status == utils.PaymentRefunded {
+				c.JSON(http.StatusConflict, gin.H{"error": "This booking has no payment to charge the food to"})
+				return
+			}
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": "Food cannot be added to a " + booking.Status + " booking"})
+			return
+		}
+
+		// A paid booking's food is charged on a card before the transaction opens, so
+		// no gateway call waits on row locks; if the order then fails the charge is
+		// refunded
+		cardCharge := booking.Status == "confirmed" && booking.Payment.Gateway != utils.WalletGateway
+		var chargeTx string
+		var charged float64
+		committed := false
+		if cardCharge {
+			var err error
+			if charged, err = utils.FoodOrderTotal(db, booking.Show.Screen.TheatreID, req.Items); err != nil {
+				if errors.Is(err, utils.ErrFoodUnavailable) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price food order"})
+				return
+			}
+			gateway, err := paymentGateway(booking.Payment)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Payment gateway unavailable"})
+				return
+			}
+			intent, err := gateway.CreateIntent(charged, booking.Payment.Method, fmt.Sprintf("booking-%d-food", booking.ID))
+			if err == nil {
+				err = gateway.Capture(intent.ID, charged)
+			}
+			if err != nil {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to charge the food order"})
+				return
+			}
+			chargeTx = intent.ID
+			defer func() {
+				if committed {
+					return
+				}
+				if _, err := gateway.Refund(chargeTx, charged, "food_order_failed"); err != nil {
+					log.Printf("food order of booking %d: refunding charge %s failed: %v", booking.ID, chargeTx, err)
+				}
+			}()
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		// Re-read the booking under the lock in case it was paid or cancelled meanwhile
+		var current models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, booking.ID).Error; err != nil || current.Status != booking.Status {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Booking changed while ordering, please retry"})
+			return
+		}
+
+		status := "pending"
+		if booking.Status == "confirmed" {
+			status = "confirmed"
+		}
+		order, err := utils.PlaceFoodOrder(tx, &current, booking.Show.Screen.TheatreID, req.Items, status)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, utils.ErrFoodUnavailable) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place food order"})
+			return
+		}
+
+		var invoice *models.Invoice
+		if booking.Status == "confirmed" {
+			if cardCharge {
+				// Prices changed since the charge was worked out
+				if order.Total != charged {
+					tx.Rollback()
+					c.JSON(http.StatusConflict, gin.H{"error": "The menu changed while ordering, please retry"})
+					return
+				}
+				order.ChargeTx = chargeTx
+			} else {
+				entry, err := utils.MoveFromWallet(tx, booking.UserID, utils.LedgerSales, order.Total, models.LedgerTransaction{
+					Type:      utils.LedgerCharge,
+					Reference: fmt.Sprintf("booking-%d-food-%d", booking.ID, order.ID),
+					BookingID: &booking.ID,
+				})
+				if err != nil {
+					tx.Rollback()
+					if errors.Is(err, utils.ErrInsufficientBalance) {
+						c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance for the food order"})
+						return
+					}
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to charge the food order"})
+					return
+				}
+				order.ChargeTx = fmt.Sprintf("ledger:%d", entry.ID)
+			}
+			if err := tx.Model(order).Update("charge_tx", order.ChargeTx).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record food charge"})
+				return
+			}
+			if err := tx.Model(booking.Payment).Update("amount", utils.RoundMoney(booking.Payment.Amount+order.Total)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment amount"})
+				return
+			}
+			if invoice, err = utils.IssueFoodInvoice(tx, &current, order); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+				return
+			}
+		}
+
+		if err := tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Updates(map[string]interface{}{
+			"total_amount": utils.RoundMoney(current.TotalAmount + order.Total),
+			"food_amount":  utils.RoundMoney(current.FoodAmount + order.Total),
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		committed = true
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":      "Food ordered successfully",
+			"food_order":   order,
+			"invoice":      invoice,
+			"total_amount": utils.RoundMoney(current.TotalAmount + order.Total),
+		})
+	}
+}
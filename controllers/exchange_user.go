@@ -0,0 +1,441 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExchangeBookingSeats moves a booking to new seats, either within its show or on
+// another showing of the same movie. A higher seat total is charged to the
+// booking's payment method, a lower one refunded, and every exchange is recorded
+// as a models.BookingExchange.
+func ExchangeBookingSeats(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			ShowID    uint     `json:"show_id"` // optional; defaults to the booking's show
+			SeatCodes []string `json:"seat_codes"`
+			RefundTo  string   `json:"refund_to"` // where a cheaper exchange is refunded: "wallet" (default) or "source"
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.SeatCodes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange data"})
+			return
+		}
+		destination, ok := refundDestination(req.RefundTo)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `Refunds go to "wallet" or "source"`})
+			return
+		}
+
+		seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate seat codes in request"})
+			return
+		}
+
+		var booking models.Booking
+		if err := db.Preload("Show.Screen").Preload("Seats").Preload("Payment").
+			Where("id = ? AND user_id = ?", c.Param("id"), userID).
+			First(&booking).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if booking.Status != "pending" && booking.Status != "confirmed" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + booking.Status + " booking cannot be exchanged"})
+			return
+		}
+		if transferredBooking(db, booking.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transferred bookings cannot be exchanged"})
+			return
+		}
+		if booking.Payment != nil && (booking.Payment.Status == utils.PaymentInitiated || booking.Payment.Status == utils.PaymentAuthorized ||
+			booking.Payment.Status == utils.PaymentCapturing) {
+			// The gateway has authorized the original total; wait for it to settle
+			c.JSON(http.StatusConflict, gin.H{"error": "A payment for this booking is in progress, try again once it completes"})
+			return
+		}
+
+		if req.ShowID == 0 {
+			req.ShowID = booking.ShowID
+		}
+		var target models.Show
+		if err := db.Preload("Screen").First(&target, req.ShowID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+
+		now := time.Now()
+		if !booking.Show.StartTime.After(now) || !target.StartTime.After(now) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Seats can only be exchanged before the show starts"})
+			return
+		}
+		if target.MovieID != booking.Show.MovieID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bookings can only be moved to a showing of the same movie"})
+			return
+		}
+		if booking.HasParking && target.Screen.TheatreID != booking.Show.Screen.TheatreID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bookings with parking can only move within the same theatre"})
+			return
+		}
+		if booking.FoodAmount > 0 && target.Screen.TheatreID != booking.Show.Screen.TheatreID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Bookings with food orders can only move within the same theatre"})
+			return
+		}
+
+		seatLayout, err := loadSeatLayout(db, &target)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+			return
+		}
+		validSeats := bookableSeats(seatLayout)
+		for _, code := range seatCodes {
+			if !validSeats[code] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seat code: " + code + ". This seat is not part of the screen layout."})
+				return
+			}
+		}
+
+		prices, err := loadSeatPrices(db, &target, seatLayout)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat prices"})
+			return
+		}
+
+		var oldSeats []string
+		var oldAmount, newAmount float64
+		for _, seat := range booking.Seats {
+			if seat.Active {
+				oldSeats = append(oldSeats, seat.SeatCode)
+				oldAmount += seat.Price
+			}
+		}
+		for _, code := range seatCodes {
+			newAmount += prices[code].Price
+		}
+
+		// The taxes and fees follow the new seats; the amounts include those added to the prices
+		charges, err := utils.CalculateCharges(db, &target, utils.ChargeBase{
+			Tickets: max(utils.RoundMoney(newAmount-booking.Discount), 0),
+			Parking: booking.ParkingFee,
+			Seats:   len(seatCodes),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load taxes and fees"})
+			return
+		}
+		oldAmount = utils.RoundMoney(oldAmount + booking.Fees + booking.Taxes)
+		newAmount = utils.RoundMoney(newAmount + charges.Added())
+		difference := utils.RoundMoney(newAmount - oldAmount)
+
+		// A dearer exchange on a card is charged before the transaction opens, so no
+		// gateway call waits on row locks; if the exchange then fails to commit the
+		// charge is refunded
+		paid := booking.Payment != nil && utils.IsPaymentCaptured(booking.Payment.Status)
+		var chargeTx string
+		committed := false
+		if paid && difference > 0 && booking.Payment.Gateway != utils.WalletGateway {
+			gateway, err := paymentGateway(booking.Payment)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Payment gateway unavailable"})
+				return
+			}
+			intent, err := gateway.CreateIntent(difference, booking.Payment.Method, fmt.Sprintf("booking-%d-exchange", booking.ID))
+			if err == nil {
+				err = gateway.Capture(intent.ID, difference)
+			}
+			if err != nil {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Failed to charge the price difference"})
+				return
+			}
+			chargeTx = intent.ID
+			defer func() {
+				if committed {
+					return
+				}
+				if _, err := gateway.Refund(chargeTx, difference, "exchange_failed"); err != nil {
+					log.Printf("exchange of booking %d: refunding charge %s failed: %v", booking.ID, chargeTx, err)
+				}
+			}()
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		// Lock both shows, lowest ID first so concurrent exchanges cannot deadlock
+		showIDs := []uint{booking.ShowID, target.ID}
+		sort.Slice(showIDs, func(i, j int) bool { return showIDs[i] < showIDs[j] })
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", showIDs).Find(&[]models.Show{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock shows for exchange"})
+			return
+		}
+
+		// Re-read the booking under the lock in case it was cancelled, paid or
+		// exchanged meanwhile; the amounts above were worked out from it
+		var current models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, booking.ID).Error; err != nil ||
+			current.Status != booking.Status || current.ShowID != booking.ShowID || current.TotalAmount != booking.TotalAmount {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Booking changed while exchanging, please retry"})
+			return
+		}
+		if holdExpired(&current) {
+			tx.Rollback()
+			c.JSON(http.StatusGone, gin.H{"error": "The seat hold has expired, please book again"})
+			return
+		}
+
+		lapsed, offers, err := releaseLapsedHolds(tx, target.ID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release expired seat holds"})
+			return
+		}
+
+		// The booking's own seats are free to reuse when exchanging within the show
+		var takenSeats []string
+		if err := tx.Model(&models.BookingSeat{}).
+			Where("show_id = ? AND active = ? AND booking_id <> ?", target.ID, true, booking.ID).
+			Pluck("seat_code", &takenSeats).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch currently booked seats"})
+			return
+		}
+		takenMap := make(map[string]bool)
+		for _, code := range takenSeats {
+			takenMap[code] = true
+		}
+
+		var conflicts []string
+		var newSeats []models.BookingSeat
+		for _, code := range seatCodes {
+			if takenMap[code] {
+				conflicts = append(conflicts, code)
+				continue
+			}
+			seat := prices[code].bookingSeat(target.ID, code, now)
+			seat.BookingID = booking.ID
+			newSeats = append(newSeats, seat)
+		}
+		if len(conflicts) > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error":             "Seats already booked by another active booking: " + strings.Join(conflicts, ", "),
+				"conflicting_seats": conflicts,
+			})
+			return
+		}
+
+		// Swap the seats; the old rows stay on the booking, inactive, as its history
+		if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ? AND exchanged_at IS NULL", booking.ID).
+			Updates(map[string]interface{}{"active": false, "exchanged_at": now}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release old seats"})
+			return
+		}
+		if err := tx.Create(&newSeats).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Seats already booked by another active booking", "conflicting_seats": seatCodes})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save new seats"})
+			return
+		}
+
+		if err := tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Updates(map[string]interface{}{
+			"show_id":      target.ID,
+			"seats_count":  len(newSeats),
+			"total_amount": utils.RoundMoney(booking.TotalAmount + difference),
+			"updated_at":   now,
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+			return
+		}
+		if err := tx.Model(&models.FoodOrder{}).Where("booking_id = ?", booking.ID).Update("show_id", target.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move food orders"})
+			return
+		}
+		if err := utils.SaveCharges(tx, booking.ID, charges); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save taxes and fees"})
+			return
+		}
+
+		if booking.Status == "confirmed" {
+			if err := tx.Model(&models.Show{}).
+				Where("id = ? AND seats_booked >= ?", booking.ShowID, booking.SeatsCount).
+				Update("seats_booked", gorm.Expr("seats_booked - ?", booking.SeatsCount)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seats count"})
+				return
+			}
+			if err := tx.Model(&models.Show{}).Where("id = ?", target.ID).
+				Update("seats_booked", gorm.Expr("seats_booked + ?", len(newSeats))).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seats count"})
+				return
+			}
+		}
+
+		// The seats given up go to the old show's waitlist first
+		freed, err := offerWaitlistSeats(tx, booking.ShowID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to offer seats to the waitlist"})
+			return
+		}
+		offers = append(offers, freed...)
+
+		exchange := models.BookingExchange{
+			BookingID:  booking.ID,
+			FromShowID: booking.ShowID,
+			ToShowID:   target.ID,
+			OldSeats:   oldSeats,
+			NewSeats:   seatCodes,
+			OldAmount:  oldAmount,
+			NewAmount:  newAmount,
+			Difference: difference,
+		}
+
+		var pendingRefund *models.Refund
+		switch {
+		case paid && difference > 0 && booking.Payment.Gateway == utils.WalletGateway:
+			entry, err := utils.MoveFromWallet(tx, booking.UserID, utils.LedgerSales, difference, models.LedgerTransaction{
+				Type:      utils.LedgerCharge,
+				Reference: fmt.Sprintf("booking-%d-exchange", booking.ID),
+				BookingID: &booking.ID,
+			})
+			if err != nil {
+				tx.Rollback()
+				if errors.Is(err, utils.ErrInsufficientBalance) {
+					c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance for the price difference"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to charge the price difference"})
+				return
+			}
+			exchange.ChargeTx = fmt.Sprintf("ledger:%d", entry.ID)
+			if err := tx.Model(booking.Payment).Update("amount", utils.RoundMoney(booking.Payment.Amount+difference)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment amount"})
+				return
+			}
+		case paid && difference > 0:
+			exchange.ChargeTx = chargeTx
+			if err := tx.Model(booking.Payment).Update("amount", utils.RoundMoney(booking.Payment.Amount+difference)).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment amount"})
+				return
+			}
+		case paid && difference < 0:
+			// Never more than is still refundable, an admin may have given some back
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(booking.Payment, booking.Payment.ID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock payment"})
+				return
+			}
+			_, refundable, err := paymentRefundTotals(tx, booking.Payment)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load refund ledger"})
+				return
+			}
+			amount := min(-difference, refundable)
+			if amount <= 0 {
+				break
+			}
+			refund := models.Refund{
+				PaymentID:  booking.Payment.ID,
+				BookingID:  booking.ID,
+				Amount:     amount,
+				SeatAmount: amount,
+				Type:       "exchange",
+				Reason:     "seat_exchange",
+			}
+			if err := refundPayment(tx, booking.Payment, &refund, destination); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refund the price difference"})
+				return
+			}
+			if err := tx.Create(&refund).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record refund"})
+				return
+			}
+			exchange.RefundID = &refund.ID
+			pendingRefund = &refund
+			if err := markRefunded(tx, booking.Payment, "seat_exchange"); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
+				return
+			}
+		}
+
+		if err := tx.Create(&exchange).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record exchange"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		committed = true
+
+		status := "held"
+		if booking.Status == "confirmed" {
+			status = "booked"
+		}
+		utils.SeatEvents.Publish(target.ID, "released", lapsed)
+		utils.SeatEvents.Publish(booking.ShowID, "released", oldSeats)
+		utils.SeatEvents.Publish(target.ID, status, seatCodes)
+		publishWaitlistOffers(offers)
+		settleRefund(db, pendingRefund)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Seats exchanged successfully",
+			"exchange": exchange,
+		})
+	}
+}
+
+// GetBookingExchanges lists the exchange history of one of the customer's bookings.
+func GetBookingExchanges(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var booking models.Booking
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&booking).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+
+		var exchanges []models.BookingExchange
+		if err := db.Where("booking_id = ?", booking.ID).Order("created_at desc").Find(&exchanges).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchanges"})
+			return
+		}
+
+		c.JSON(http.StatusOK, exchanges)
+	}
+}
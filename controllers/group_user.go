@@ -0,0 +1,647 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxGroupSeats = 20
+	// maxReservedGroupSeats caps the unclaimed seats one organiser holds across
+	// their open group bookings
+	maxReservedGroupSeats = 2 * maxGroupSeats
+)
+
+// loadUserGroup fetches a group booking the user organises or was invited to.
+func loadUserGroup(db *gorm.DB, id string, userID uint) (*models.GroupBooking, error) {
+	var group models.GroupBooking
+	err := db.Preload("Show.Movie").Preload("Invites", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Invites.Booking").
+		Where("id = ? AND (organiser_id = ? OR id IN (?))", id, userID,
+			db.Model(&models.GroupInvite{}).Select("group_id").Where("user_id = ?", userID)).
+		First(&group).Error
+	return &group, err
+}
+
+// groupOpen reports why a group booking takes no more invites or claims, with
+// the HTTP status to use.
+func groupOpen(group *models.GroupBooking, now time.Time) (int, string) {
+	if group.Status != "open" || !group.Deadline.After(now) {
+		return http.StatusGone, "This group booking is closed"
+	}
+	return 0, ""
+}
+
+// CreateGroupBooking — User: reserve seats of a show for a group. The seats are
+// held until the deadline for the members to claim and pay for; the deadline is
+// at most utils.GroupClaimWindow away, its default, and never after the show
+// starts. An organiser can hold at most maxReservedGroupSeats unclaimed seats.
+func CreateGroupBooking(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			ShowID    uint       `json:"show_id"`
+			SeatCodes []string   `json:"seat_codes"`
+			Deadline  *time.Time `json:"deadline"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.SeatCodes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group booking data"})
+			return
+		}
+		if len(req.SeatCodes) > maxGroupSeats {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A group booking holds at most %d seats", maxGroupSeats)})
+			return
+		}
+		seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate seat codes in request"})
+			return
+		}
+
+		var show models.Show
+		if err := db.First(&show, req.ShowID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+		now := time.Now()
+		if !show.StartTime.After(now) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This show has already started"})
+			return
+		}
+
+		deadline := now.Add(utils.GroupClaimWindow())
+		if deadline.After(show.StartTime) {
+			deadline = show.StartTime
+		}
+		if req.Deadline != nil {
+			if !req.Deadline.After(now) || req.Deadline.After(deadline) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The deadline must be in the future, within %s and no later than the show's start", utils.GroupClaimWindow())})
+				return
+			}
+			deadline = *req.Deadline
+		}
+
+		seatLayout, err := loadSeatLayout(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+			return
+		}
+		validSeats := bookableSeats(seatLayout)
+		for _, code := range seatCodes {
+			if !validSeats[code] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seat code: " + code + ". This seat is not part of the screen layout."})
+				return
+			}
+		}
+		prices, err := loadSeatPrices(db, &show, seatLayout)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat prices"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, show.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show for booking"})
+			return
+		}
+		lapsed, offers, err := releaseLapsedHolds(tx, show.ID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release expired seat holds"})
+			return
+		}
+
+		// The organiser's row serialises their reservations across shows
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, userID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check reserved seats"})
+			return
+		}
+		var reserved int64
+		if err := tx.Model(&models.Booking{}).Select("COALESCE(SUM(seats_count), 0)").
+			Where("user_id = ? AND status = ? AND group_id IS NOT NULL", userID, "reserved").
+			Scan(&reserved).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check reserved seats"})
+			return
+		}
+		if int(reserved)+len(seatCodes) > maxReservedGroupSeats {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can hold at most %d unclaimed group seats, %d are reserved already", maxReservedGroupSeats, reserved)})
+			return
+		}
+
+		var taken []string
+		if err := tx.Model(&models.BookingSeat{}).Where("show_id = ? AND active = ? AND seat_code IN ?", show.ID, true, seatCodes).
+			Pluck("seat_code", &taken).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch currently booked seats"})
+			return
+		}
+		if len(taken) > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error":             "Seats already booked by another active booking: " + strings.Join(taken, ", "),
+				"conflicting_seats": taken,
+			})
+			return
+		}
+
+		// The reservation is not paid for: each member pays for the seats they claim
+		var seats []models.BookingSeat
+		for _, code := range seatCodes {
+			seats = append(seats, prices[code].bookingSeat(show.ID, code, now))
+		}
+		hold := models.Booking{
+			UserID:     userID,
+			ShowID:     show.ID,
+			SeatsCount: len(seats),
+			Status:     "reserved",
+			ExpiresAt:  &deadline,
+			Seats:      seats,
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Seats already booked by another active booking", "conflicting_seats": seatCodes})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve seats"})
+			return
+		}
+
+		group := models.GroupBooking{
+			OrganiserID:   userID,
+			ShowID:        show.ID,
+			HoldBookingID: hold.ID,
+			Deadline:      deadline,
+			Status:        "open",
+		}
+		if err := tx.Create(&group).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group booking"})
+			return
+		}
+		if err := tx.Model(&hold).Update("group_id", group.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group booking"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		utils.SeatEvents.Publish(show.ID, "released", lapsed)
+		publishWaitlistOffers(offers)
+		utils.SeatEvents.Publish(show.ID, "held", seatCodes)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":        "Seats reserved, invite your group to claim them",
+			"group":          group,
+			"reserved_seats": seatCodes,
+		})
+	}
+}
+
+// GetGroupBooking — User: a group booking they organise or were invited to, with
+// its invites and the seats nobody has claimed yet.
+func GetGroupBooking(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := loadUserGroup(db, c.Param("id"), c.GetUint("userId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group booking not found"})
+			return
+		}
+
+		var unclaimed []string
+		if group.Status == "open" {
+			unclaimed = bookingSeatCodes(db, group.HoldBookingID)
+		}
+		c.JSON(http.StatusOK, gin.H{"group": group, "unclaimed_seats": unclaimed})
+	}
+}
+
+// InviteToGroup — User: offer reserved seats of their group booking to another
+// registered user, by email.
+func InviteToGroup(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email     string   `json:"email"`
+			SeatCodes []string `json:"seat_codes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Email) == "" || len(req.SeatCodes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite data"})
+			return
+		}
+		seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate seat codes in request"})
+			return
+		}
+
+		var invitee models.User
+		if err := db.Where("email = ? AND blocked = ?", strings.TrimSpace(req.Email), false).First(&invitee).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No registered user with that email"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		var group models.GroupBooking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND organiser_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&group).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group booking not found"})
+			return
+		}
+		if status, msg := groupOpen(&group, time.Now()); status != 0 {
+			tx.Rollback()
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+
+		var open []models.GroupInvite
+		if err := tx.Where("group_id = ? AND status = ?", group.ID, "invited").Find(&open).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+			return
+		}
+		offered := make(map[string]bool)
+		for _, invite := range open {
+			if invite.UserID == invitee.ID {
+				tx.Rollback()
+				c.JSON(http.StatusConflict, gin.H{"error": "This user already has an open invite", "invite_id": invite.ID})
+				return
+			}
+			for _, code := range invite.SeatCodes {
+				offered[code] = true
+			}
+		}
+
+		reserved := make(map[string]bool)
+		for _, code := range bookingSeatCodes(tx, group.HoldBookingID) {
+			reserved[code] = true
+		}
+		var unavailable []string
+		for _, code := range seatCodes {
+			if !reserved[code] || offered[code] {
+				unavailable = append(unavailable, code)
+			}
+		}
+		if len(unavailable) > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error":             "Seats not free to offer: " + strings.Join(unavailable, ", "),
+				"unavailable_seats": unavailable,
+			})
+			return
+		}
+
+		invite := models.GroupInvite{
+			GroupID:   group.ID,
+			UserID:    invitee.ID,
+			Email:     invitee.Email,
+			SeatCodes: seatCodes,
+			Status:    "invited",
+		}
+		if err := tx.Create(&invite).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Invite sent", "invite": invite})
+	}
+}
+
+// RevokeGroupInvite — User: withdraw an invite of their group booking that was
+// not claimed yet. Its seats stay reserved for the group.
+func RevokeGroupInvite(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Model(&models.GroupInvite{}).
+			Where("id = ? AND status = ? AND group_id IN (?)", c.Param("inviteId"), "invited",
+				db.Model(&models.GroupBooking{}).Select("id").Where("id = ? AND organiser_id = ?", c.Param("id"), c.GetUint("userId"))).
+			Update("status", "revoked")
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open invite not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+	}
+}
+
+// CancelGroupBooking — User: release the seats of their group booking that nobody
+// has claimed. Seats already claimed stay with their claimants' bookings.
+func CancelGroupBooking(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		// Lock the show before the group, as claims do
+		var showIDs []uint
+		if err := tx.Model(&models.GroupBooking{}).Where("id = ?", c.Param("id")).Pluck("show_id", &showIDs).Error; err != nil || len(showIDs) == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group booking not found"})
+			return
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, showIDs[0]).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show"})
+			return
+		}
+		var group models.GroupBooking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND organiser_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&group).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group booking not found"})
+			return
+		}
+		if group.Status != "open" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + group.Status + " group booking cannot be cancelled"})
+			return
+		}
+
+		released := bookingSeatCodes(tx, group.HoldBookingID)
+		if err := tx.Model(&models.Booking{}).Where("id = ? AND status = ?", group.HoldBookingID, "reserved").
+			Updates(map[string]interface{}{"status": "cancelled", "updated_at": time.Now()}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release seats"})
+			return
+		}
+		if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ?", group.HoldBookingID).Update("active", false).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release seats"})
+			return
+		}
+		if err := tx.Model(&models.GroupInvite{}).Where("group_id = ? AND status = ?", group.ID, "invited").
+			Update("status", "revoked").Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invites"})
+			return
+		}
+		if err := tx.Model(&group).Update("status", "cancelled").Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel group booking"})
+			return
+		}
+		offers, err := offerWaitlistSeats(tx, group.ShowID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to offer seats to the waitlist"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		utils.SeatEvents.Publish(group.ShowID, "released", released)
+		publishWaitlistOffers(offers)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Group booking cancelled", "released_seats": released})
+	}
+}
+
+// GetUserGroupInvites — User: the group seats they have been invited to claim.
+func GetUserGroupInvites(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var invites []models.GroupInvite
+		if err := db.Preload("Group.Show.Movie").Preload("Booking").
+			Where("user_id = ?", c.GetUint("userId")).
+			Order("created_at desc").Find(&invites).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+			return
+		}
+		c.JSON(http.StatusOK, invites)
+	}
+}
+
+// DeclineGroupInvite — User: turn down an invite. The seats go back to the
+// organiser to offer to someone else.
+func DeclineGroupInvite(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Model(&models.GroupInvite{}).
+			Where("id = ? AND user_id = ? AND status = ?", c.Param("id"), c.GetUint("userId"), "invited").
+			Update("status", "declined")
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline invite"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Open invite not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Invite declined"})
+	}
+}
+
+// ClaimGroupInvite — User: take the seats of an invite into a booking of their own.
+// The booking is pending until paid like any other (or paid from the wallet here
+// with payment_method "wallet"), and its hold lasts until the group's deadline.
+func ClaimGroupInvite(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			PaymentMethod string `json:"payment_method"`
+		}
+		_ = c.ShouldBindJSON(&req)
+
+		var invite models.GroupInvite
+		if err := db.Preload("Group.Show").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&invite).Error; err != nil || invite.Group == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+		show := invite.Group.Show
+
+		seatLayout, err := loadSeatLayout(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+			return
+		}
+		prices, err := loadSeatPrices(db, &show, seatLayout)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat prices"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		// Lock the show first, as bookings do, then the group and the invite
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, show.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show for booking"})
+			return
+		}
+		var group models.GroupBooking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, invite.GroupID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group booking not found"})
+			return
+		}
+		now := time.Now()
+		if status, msg := groupOpen(&group, now); status != 0 {
+			tx.Rollback()
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invite, invite.ID).Error; err != nil || invite.Status != "invited" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "This invite is no longer open", "status": invite.Status})
+			return
+		}
+
+		// Move the seats from the group's reservation to the claimant's booking; the
+		// reservation must still hold them
+		var hold models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, group.HoldBookingID).Error; err != nil || hold.Status != "reserved" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "The group's reservation has lapsed"})
+			return
+		}
+		result := tx.Where("booking_id = ? AND active = ? AND seat_code IN ?", group.HoldBookingID, true, invite.SeatCodes).Delete(&models.BookingSeat{})
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim seats"})
+			return
+		}
+		if int(result.RowsAffected) != len(invite.SeatCodes) {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Some of these seats are no longer reserved for the group"})
+			return
+		}
+
+		var seats []models.BookingSeat
+		var subtotal float64
+		for _, code := range invite.SeatCodes {
+			seats = append(seats, prices[code].bookingSeat(show.ID, code, now))
+			subtotal += prices[code].Price
+		}
+		charges, err := utils.CalculateCharges(tx, &show, utils.ChargeBase{Tickets: utils.RoundMoney(subtotal), Seats: len(seats)})
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load taxes and fees"})
+			return
+		}
+
+		deadline := group.Deadline
+		booking := models.Booking{
+			UserID:        userID,
+			ShowID:        show.ID,
+			SeatsCount:    len(seats),
+			TotalAmount:   utils.RoundMoney(subtotal + charges.Added()),
+			Status:        "pending",
+			PaymentMethod: req.PaymentMethod,
+			Fees:          charges.Fees,
+			Taxes:         charges.Taxes,
+			ExpiresAt:     &deadline,
+			GroupID:       &group.ID,
+			Seats:         seats,
+		}
+		if err := tx.Create(&booking).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Seats already booked by another active booking", "conflicting_seats": invite.SeatCodes})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+		if err := utils.SaveCharges(tx, booking.ID, charges); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save taxes and fees"})
+			return
+		}
+		if err := tx.Model(&models.Booking{}).Where("id = ?", group.HoldBookingID).
+			Update("seats_count", gorm.Expr("seats_count - ?", len(seats))).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reservation"})
+			return
+		}
+		if err := tx.Model(&invite).Updates(map[string]interface{}{
+			"status":     "claimed",
+			"booking_id": booking.ID,
+			"claimed_at": now,
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invite"})
+			return
+		}
+
+		paid := booking.TotalAmount == 0
+		if paid {
+			err = utils.ConfirmPaidBooking(tx, &booking)
+		} else if req.PaymentMethod == utils.WalletGateway {
+			_, err = payFromWallet(tx, &booking, nil)
+			paid = true
+		}
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, utils.ErrInsufficientBalance) {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance: " + err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm booking"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		if paid {
+			utils.SeatEvents.Publish(show.ID, "booked", invite.SeatCodes)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Seats claimed",
+			"booking":    booking,
+			"paid":       paid,
+			"expires_at": deadline,
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loyaltyExpiringWithin is how far ahead GetLoyaltySummary warns about expiring points.
+const loyaltyExpiringWithin = 30 * 24 * time.Hour
+
+// GetLoyaltySummary — User: their points balance, what it is worth, points about
+// to expire and the ledger, newest first (?limit=, default 50).
+func GetLoyaltySummary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+		now := time.Now()
+
+		// write off lapsed lots so the history shows them
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return utils.ExpireLoyaltyPoints(tx, userID, now)
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update loyalty points"})
+			return
+		}
+
+		balance, err := utils.LoyaltyBalance(db, userID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch loyalty balance"})
+			return
+		}
+
+		var expiring int
+		if err := db.Model(&models.LoyaltyEntry{}).Select("COALESCE(SUM(remaining), 0)").
+			Where("user_id = ? AND remaining > 0 AND expires_at <= ?", userID, now.Add(loyaltyExpiringWithin)).
+			Scan(&expiring).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch loyalty balance"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		var history []models.LoyaltyEntry
+		if err := db.Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(limit).Find(&history).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch loyalty history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"balance":        balance,
+			"value":          utils.RoundMoney(float64(balance) * utils.LoyaltyPointValue()),
+			"point_value":    utils.LoyaltyPointValue(),
+			"points_per_100": utils.LoyaltyPointsPer100(),
+			"expiring_soon":  expiring,
+			"history":        history,
+		})
+	}
+}
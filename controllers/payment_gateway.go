@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// paymentGateway returns the gateway a payment went through. Payments made
+// before gateways existed belong to the active one.
+func paymentGateway(payment *models.Payment) (utils.PaymentGateway, error) {
+	if payment.Gateway == "" {
+		return utils.ActivePaymentGateway(), nil
+	}
+	gateway, ok := utils.PaymentGatewayByName(payment.Gateway)
+	if !ok {
+		return nil, errors.New("payment gateway " + payment.Gateway + " is not available")
+	}
+	return gateway, nil
+}
+
+// refundThroughGateway pays a recorded refund of a captured payment back and
+// returns the gateway's refund reference(s). Extra charges from seat exchanges and later food
+// orders are separate captures, so the refund is drawn from the original capture first and then from
+// each extra charge, skipping what earlier refunds already took.
+func refundThroughGateway(tx *gorm.DB, payment *models.Payment, refund *models.Refund) (string, error) {
+	gateway, err := paymentGateway(payment)
+	if err != nil {
+		return "", err
+	}
+
+	charges, err := utils.ExtraCharges(tx, payment.BookingID)
+	if err != nil {
+		return "", err
+	}
+	var refunded float64
+	if err := tx.Model(&models.Refund{}).Where("payment_id = ? AND id < ?", payment.ID, refund.ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+		return "", err
+	}
+
+	type capture struct {
+		intentID string
+		amount   float64
+	}
+	original := payment.Amount
+	captures := []capture{}
+	for _, charge := range charges {
+		original -= charge.Amount
+		captures = append(captures, capture{charge.ChargeTx, charge.Amount})
+	}
+	captures = append([]capture{{payment.ProviderTx, original}}, captures...)
+
+	var refs []string
+	remaining := utils.RoundMoney(refund.Amount)
+	for _, c := range captures {
+		available := c.amount
+		if refunded > 0 {
+			taken := min(refunded, available)
+			refunded -= taken
+			available -= taken
+		}
+		take := utils.RoundMoney(min(available, remaining))
+		if take <= 0 {
+			continue
+		}
+		ref, err := gateway.Refund(c.intentID, take, refund.Reason)
+		if err != nil {
+			return strings.Join(refs, ","), err
+		}
+		refs = append(refs, ref)
+		remaining = utils.RoundMoney(remaining - take)
+		if remaining <= 0 {
+			break
+		}
+	}
+	if remaining > 0 {
+		return strings.Join(refs, ","), errors.New("refund exceeds the amount captured")
+	}
+	return strings.Join(refs, ","), nil
+}
+
+// Refund destinations.
+const (
+	refundToWallet = "wallet" // the customer's wallet, the default
+	refundToSource = "source" // the means of payment, through the gateway
+)
+
+// refundDestination validates a requested refund destination; empty means the wallet.
+func refundDestination(requested string) (string, bool) {
+	switch requested {
+	case "", refundToWallet:
+		return refundToWallet, true
+	case refundToSource:
+		return refundToSource, true
+	}
+	return "", false
+}
+
+// Refund statuses. Wallet refunds are processed in the transaction that records
+// them; refunds to the source stay pending until the gateway has paid them.
+const (
+	refundPending    = "pending"    // recorded, not yet sent to the gateway
+	refundProcessing = "processing" // being sent to the gateway
+	refundProcessed  = "processed"
+	refundFailed     = "failed" // the gateway paid only part of it; needs an admin
+)
+
+// refundPayment pays amount of a captured payment back to destination and fills
+// in where the refund went and its reference. Payments made from the wallet are
+// always refunded into the wallet of the user who paid. A refund to the source
+// is only marked pending: the caller records it and calls settleRefund once the
+// transaction has committed.
+func refundPayment(tx *gorm.DB, payment *models.Payment, refund *models.Refund, destination string) error {
+	if destination == refundToSource && payment.Gateway != utils.WalletGateway {
+		if _, err := paymentGateway(payment); err != nil {
+			return err
+		}
+		refund.Destination, refund.Status = refundToSource, refundPending
+		return nil
+	}
+
+	// A booking handed over to someone else is still refunded to whoever paid
+	payer, err := bookingPayer(tx, payment.BookingID)
+	if err != nil {
+		return err
+	}
+	entry, err := utils.MoveToWallet(tx, payer, utils.LedgerSales, refund.Amount, models.LedgerTransaction{
+		Type:      utils.LedgerRefund,
+		Reference: refund.Reason,
+		BookingID: &payment.BookingID,
+	})
+	if err != nil {
+		return err
+	}
+	refund.Destination, refund.ProviderRef = refundToWallet, fmt.Sprintf("ledger:%d", entry.ID)
+	refund.Status = refundProcessed
+	return nil
+}
+
+// settleRefund sends a committed pending refund through the gateway and marks it
+// processed. The refund is claimed first, so the request that recorded it and
+// the refund settler never both send it. A refund the gateway turned down
+// entirely goes back to pending to be retried; one it paid only in part is
+// marked failed with the references it got.
+func settleRefund(db *gorm.DB, refund *models.Refund) {
+	if refund == nil || refund.Status != refundPending {
+		return
+	}
+	claim := db.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, refundPending).Update("status", refundProcessing)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+
+	var payment models.Payment
+	if err := db.First(&payment, refund.PaymentID).Error; err != nil {
+		log.Printf("refund %d: failed to load payment: %v", refund.ID, err)
+		db.Model(&models.Refund{}).Where("id = ?", refund.ID).Update("status", refundPending)
+		return
+	}
+
+	ref, err := refundThroughGateway(db, &payment, refund)
+	status := refundProcessed
+	if err != nil {
+		log.Printf("refund %d: gateway refund failed: %v", refund.ID, err)
+		status = refundPending
+		if ref != "" {
+			status = refundFailed
+		}
+	}
+	if err := db.Model(&models.Refund{}).Where("id = ?", refund.ID).
+		Updates(map[string]interface{}{"status": status, "provider_ref": ref}).Error; err != nil {
+		log.Printf("refund %d: failed to record gateway result %q: %v", refund.ID, ref, err)
+		return
+	}
+	refund.Status, refund.ProviderRef = status, ref
+}
+
+// StartGatewaySettler finishes, each interval, the gateway calls that were
+// recorded but not completed: refunds to the source that are still pending, such
+// as those the gateway turned down, and payments and wallet top-ups left
+// capturing by a process that stopped before recording the outcome.
+func StartGatewaySettler(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			stale := time.Now().Add(-interval)
+
+			var refunds []models.Refund
+			if err := db.Where("status = ? AND created_at < ?", refundPending, stale).
+				Order("id").Find(&refunds).Error; err != nil {
+				log.Printf("gateway settler: failed to list pending refunds: %v", err)
+			}
+			for i := range refunds {
+				settleRefund(db, &refunds[i])
+			}
+
+			var capturing []models.Payment
+			if err := db.Where("status = ? AND provider_tx IN (?)", utils.PaymentCapturing,
+				db.Model(&models.Payment{}).Select("provider_tx").Where("status = ? AND updated_at < ?", utils.PaymentCapturing, stale)).
+				Order("id").Find(&capturing).Error; err != nil {
+				log.Printf("gateway settler: failed to list payments being captured: %v", err)
+			}
+			// the shares of a cart's payment are captured together
+			intents := make(map[string][]models.Payment)
+			var order []string
+			for _, payment := range capturing {
+				key := payment.Gateway + "|" + payment.ProviderTx
+				if _, seen := intents[key]; !seen {
+					order = append(order, key)
+				}
+				intents[key] = append(intents[key], payment)
+			}
+			for _, key := range order {
+				payments := intents[key]
+				gateway, err := paymentGateway(&payments[0])
+				if err != nil {
+					continue
+				}
+				if _, err := capturePayments(db, gateway, payments); err != nil {
+					log.Printf("gateway settler: failed to capture payment %d: %v", payments[0].ID, err)
+				}
+			}
+
+			var topups []models.WalletTopup
+			if err := db.Where("status = ? AND updated_at < ?", utils.PaymentCapturing, stale).
+				Order("id").Find(&topups).Error; err != nil {
+				log.Printf("gateway settler: failed to list top-ups being captured: %v", err)
+			}
+			for i := range topups {
+				gateway, ok := utils.PaymentGatewayByName(topups[i].Gateway)
+				if !ok {
+					continue
+				}
+				if _, err := captureTopup(db, gateway, &topups[i]); err != nil {
+					log.Printf("gateway settler: failed to capture top-up %d: %v", topups[i].ID, err)
+				}
+			}
+		}
+	}()
+}
+
+// markRefunded moves a payment to refunded or partially_refunded according to
+// everything refunded on it so far, including a refund just recorded.
+func markRefunded(tx *gorm.DB, payment *models.Payment, reason string) error {
+	var refunded float64
+	if err := tx.Model(&models.Refund{}).Where("payment_id = ?", payment.ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+		return err
+	}
+
+	status := utils.PaymentPartiallyRefunded
+	if utils.RoundMoney(refunded) >= utils.RoundMoney(payment.Amount) {
+		status = utils.PaymentRefunded
+	}
+	return utils.TransitionPayment(tx, payment, status, reason, nil)
+}
@@ -2,6 +2,9 @@ package controllers
 
 import (
 	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -9,6 +12,79 @@ import (
 	"gorm.io/gorm"
 )
 
+// checkPayable reports why a booking cannot be paid for right now, with the HTTP status to use.
+func checkPayable(booking *models.Booking) (int, string) {
+	if booking.Status == "expired" || holdExpired(booking) {
+		return http.StatusGone, "Seat hold has expired, please book again"
+	}
+	if booking.Status != "pending" {
+		return http.StatusConflict, "A " + booking.Status + " booking cannot be paid"
+	}
+	return 0, ""
+}
+
+// paymentStarted is the response to a new payment attempt.
+func paymentStarted(c *gin.Context, message string, payment *models.Payment, intent *utils.PaymentIntent) {
+	c.JSON(http.StatusOK, gin.H{
+		"message":      message,
+		"payment_id":   payment.ID,
+		"gateway":      payment.Gateway,
+		"intent_id":    intent.ID,
+		"checkout_url": intent.CheckoutURL,
+		"status":       payment.Status,
+		"attempts":     payment.Attempts,
+	})
+}
+
+// retryPayment starts a new attempt on a failed payment of a pending booking. The
+// payment row is reused, so a booking keeps a single payment and the earlier
+// attempts stay visible in its transition history.
+func retryPayment(c *gin.Context, db *gorm.DB, payment *models.Payment, booking *models.Booking, method string) {
+	if payment.Status != utils.PaymentFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed payments can be retried", "payment_id": payment.ID, "status": payment.Status})
+		return
+	}
+	if method == "" {
+		method = payment.Method
+	}
+
+	gateway := utils.ActivePaymentGateway()
+	intent, err := gateway.CreateIntent(booking.TotalAmount, method, fmt.Sprintf("booking-%d", booking.ID))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment gateway unavailable, please try again"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return utils.TransitionPayment(tx, payment, utils.PaymentInitiated, fmt.Sprintf("retry, attempt %d", payment.Attempts+1), map[string]interface{}{
+			"amount":      booking.TotalAmount,
+			"method":      method,
+			"gateway":     gateway.Name(),
+			"provider_tx": intent.ID,
+			"attempts":    gorm.Expr("attempts + 1"),
+		})
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidPaymentTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment was updated in the meantime, please try again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry payment"})
+		return
+	}
+
+	payment.Amount = booking.TotalAmount
+	payment.Method = method
+	payment.Gateway = gateway.Name()
+	payment.ProviderTx = intent.ID
+	payment.Attempts++
+	paymentStarted(c, "Payment retried", payment, intent)
+}
+
+// InitiatePayment starts a payment for a pending booking with the active payment
+// gateway. The booking is confirmed later, when the gateway's webhook reports the
+// payment as authorized (see PaymentWebhook). A booking whose payment failed gets
+// a new attempt on the same payment.
 func InitiatePayment(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -28,115 +104,130 @@ func InitiatePayment(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if status, msg := checkPayable(&booking); status != 0 {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+		if booking.CartID != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "This booking is paid for with its cart", "cart_id": *booking.CartID})
+			return
+		}
+
 		if booking.TotalAmount != req.Amount {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount mismatch with booking total"})
 			return
 		}
 
+		if req.Method == utils.WalletGateway {
+			walletCheckout(c, db, booking.ID)
+			return
+		}
+
+		var existing models.Payment
+		if err := db.Where("booking_id = ?", req.BookingID).First(&existing).Error; err == nil {
+			if existing.Status == utils.PaymentFailed {
+				retryPayment(c, db, &existing, &booking, req.Method)
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment already initiated for this booking", "payment_id": existing.ID, "status": existing.Status})
+			return
+		}
+
+		gateway := utils.ActivePaymentGateway()
+		intent, err := gateway.CreateIntent(req.Amount, req.Method, fmt.Sprintf("booking-%d", booking.ID))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Payment gateway unavailable, please try again"})
+			return
+		}
+
 		payment := models.Payment{
-			BookingID: req.BookingID,
-			Amount:    req.Amount,
-			Method:    req.Method,
-			Status:    "initiated",
-			CreatedAt: time.Now(),
+			BookingID:  req.BookingID,
+			Amount:     req.Amount,
+			Method:     req.Method,
+			Gateway:    gateway.Name(),
+			ProviderTx: intent.ID,
+			Status:     utils.PaymentInitiated,
+			Attempts:   1,
+			CreatedAt:  time.Now(),
 		}
 
-		if err := db.Create(&payment).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&payment).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.PaymentTransition{PaymentID: payment.ID, ToStatus: utils.PaymentInitiated, Reason: "payment initiated"}).Error
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				db.Where("booking_id = ?", req.BookingID).First(&existing)
+				c.JSON(http.StatusConflict, gin.H{"error": "Payment already initiated for this booking", "payment_id": existing.ID})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initiate payment"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Payment initiated",
-			"payment_id": payment.ID,
-			// "mock_redirect": "/api/payments/mock/confirm/" + strconv.Itoa(int(payment.ID)),
-		})
+		paymentStarted(c, "Payment initiated", &payment, intent)
 	}
 
 }
 
-func MockConfirmPayment(db *gorm.DB) gin.HandlerFunc {
+// RetryPayment — User: start a new attempt on a failed payment of their pending booking.
+func RetryPayment(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-
-		id := c.Param("id")
-
-		tx := db.Begin()
-		defer func() {
-			if r := recover(); r != nil {
-				tx.Rollback()
-			}
-		}()
+		var req struct {
+			Method string `json:"method"` // optional; defaults to the failed attempt's method
+		}
+		_ = c.ShouldBindJSON(&req)
 
 		var payment models.Payment
-		if err := tx.First(&payment, id).Error; err != nil {
-			tx.Rollback()
+		if err := db.Joins("JOIN bookings ON bookings.id = payments.booking_id").
+			Where("payments.id = ? AND bookings.user_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&payment).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 			return
 		}
 
 		var booking models.Booking
-		if err := tx.First(&booking, payment.BookingID).Error; err != nil {
-			tx.Rollback()
+		if err := db.First(&booking, payment.BookingID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 			return
 		}
-
-		if booking.Status == "confirmed" {
-			tx.Commit()
-			c.JSON(http.StatusOK, gin.H{
-				"message": "Payment already confirmed",
-				"booking": booking.ID,
-				"status":  booking.Status,
-			})
+		if status, msg := checkPayable(&booking); status != 0 {
+			c.JSON(status, gin.H{"error": msg})
 			return
 		}
-
-		wasConfirmed := booking.Status == "confirmed"
-
-		// mock successful payment (90 % success rate)
-		payment.Status = "completed"
-		payment.UpdatedAt = time.Now()
-		booking.Status = "confirmed"
-
-		if err := tx.Save(&payment).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
-			return
-		}
-		if err := tx.Save(&booking).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking status"})
+		if booking.CartID != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "This booking is paid for with its cart", "cart_id": *booking.CartID})
 			return
 		}
 
-		if !wasConfirmed {
-			var show models.Show
-			if err := tx.First(&show, booking.ShowID).Error; err == nil {
-				show.SeatsBooked += booking.SeatsCount
-				if err := tx.Save(&show).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seats count"})
-					return
-				}
-			}
-		}
+		retryPayment(c, db, &payment, &booking, req.Method)
+	}
+}
 
-		if err := tx.Commit().Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+// GetPaymentDetails — User: one of their payments with its transition history.
+func GetPaymentDetails(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payment models.Payment
+		if err := db.Preload("Transitions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Preload("Refunds").
+			Joins("JOIN bookings ON bookings.id = payments.booking_id").
+			Where("payments.id = ? AND bookings.user_id = ?", c.Param("id"), c.GetUint("userId")).
+			First(&payment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":  "Payment successful (mock)",
-			"booking":  booking.ID,
-			"status":   booking.Status,
-			"amount":   payment.Amount,
-			"method":   payment.Method,
-			"datetime": payment.UpdatedAt,
-		})
+		c.JSON(http.StatusOK, payment)
 	}
 }
 
+// holdExpired reports whether a pending booking's seat hold ran out before the sweeper caught it.
+func holdExpired(booking *models.Booking) bool {
+	return booking.Status == "pending" && booking.ExpiresAt != nil && time.Now().After(*booking.ExpiresAt)
+}
+
 func GetUserPayments(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// confirmAuthorizedPayment records an authorized payment and, while its bookings
+// still hold their seats, marks it capturing; capturePayments takes the money
+// once this transaction has committed, and until then the holds do not expire.
+// A cart checkout is one gateway payment shared by the payments of the cart's
+// bookings, so they are all captured at once or not at all. It returns a short
+// outcome for the webhook log. If any booking's hold lapsed in the meantime
+// nothing is charged: the payments are cancelled and the uncaptured
+// authorization expires at the gateway.
+func confirmAuthorizedPayment(tx *gorm.DB, gateway utils.PaymentGateway, payments []models.Payment) (string, error) {
+	for _, payment := range payments {
+		if !utils.CanTransitionPayment(payment.Status, utils.PaymentAuthorized) {
+			return "ignored, payment is " + payment.Status, nil
+		}
+	}
+	for i := range payments {
+		if err := utils.TransitionPayment(tx, &payments[i], utils.PaymentAuthorized, "authorized by "+gateway.Name(), nil); err != nil {
+			return "", err
+		}
+	}
+
+	bookings := make([]models.Booking, len(payments))
+	for i, payment := range payments {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bookings[i], payment.BookingID).Error; err != nil {
+			return "", err
+		}
+	}
+
+	for _, booking := range bookings {
+		if booking.Status != "pending" || holdExpired(&booking) {
+			reason := "booking is " + booking.Status
+			if holdExpired(&booking) {
+				reason = "seat hold expired"
+			}
+			for i := range payments {
+				if err := utils.TransitionPayment(tx, &payments[i], utils.PaymentCancelled, reason, nil); err != nil {
+					return "", err
+				}
+			}
+			return reason + ", not captured", nil
+		}
+	}
+
+	for i := range payments {
+		if err := utils.TransitionPayment(tx, &payments[i], utils.PaymentCapturing, "capture requested", nil); err != nil {
+			return "", err
+		}
+	}
+	return "capturing", nil
+}
+
+// capturePayments captures payments marked capturing, all shares of a cart's
+// payment together, and confirms their bookings. The gateway is called outside
+// any transaction; since captures can be retried, the gateway settler calls it
+// again for payments a crash left capturing. A booking an admin cancelled while
+// its payment was being captured gets its share refunded to the source.
+func capturePayments(db *gorm.DB, gateway utils.PaymentGateway, payments []models.Payment) (string, error) {
+	var amount float64
+	ids := make([]uint, len(payments))
+	for i, payment := range payments {
+		amount += payment.Amount
+		ids[i] = payment.ID
+	}
+	captureErr := gateway.Capture(payments[0].ProviderTx, utils.RoundMoney(amount))
+
+	var result string
+	var confirmed []models.Booking
+	var refunds []*models.Refund
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current []models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&current).Error; err != nil {
+			return err
+		}
+		for _, payment := range current {
+			if payment.Status != utils.PaymentCapturing {
+				result = "ignored, payment is " + payment.Status
+				return nil
+			}
+		}
+
+		if captureErr != nil {
+			for i := range current {
+				if err := utils.TransitionPayment(tx, &current[i], utils.PaymentFailed, "capture failed: "+captureErr.Error(), nil); err != nil {
+					return err
+				}
+			}
+			result = "capture failed"
+			return nil
+		}
+
+		var cartID *uint
+		for i := range current {
+			var booking models.Booking
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, current[i].BookingID).Error; err != nil {
+				return err
+			}
+			if err := utils.TransitionPayment(tx, &current[i], utils.PaymentCaptured, "captured", nil); err != nil {
+				return err
+			}
+			if booking.Status != "pending" {
+				refund := &models.Refund{
+					PaymentID:  current[i].ID,
+					BookingID:  booking.ID,
+					Amount:     current[i].Amount,
+					SeatAmount: utils.RoundMoney(current[i].Amount - booking.ParkingFee - booking.FoodAmount),
+					Type:       "full",
+					Reason:     "booking_" + booking.Status,
+				}
+				if err := refundPayment(tx, &current[i], refund, refundToSource); err != nil {
+					return err
+				}
+				if err := tx.Create(refund).Error; err != nil {
+					return err
+				}
+				if err := markRefunded(tx, &current[i], "booking "+booking.Status+" during capture"); err != nil {
+					return err
+				}
+				refunds = append(refunds, refund)
+				continue
+			}
+			if err := utils.ConfirmPaidBooking(tx, &booking); err != nil {
+				return err
+			}
+			confirmed = append(confirmed, booking)
+			cartID = booking.CartID
+		}
+
+		switch {
+		case cartID != nil:
+			result = fmt.Sprintf("captured, %d bookings of cart %d confirmed", len(confirmed), *cartID)
+			return tx.Model(&models.Cart{}).Where("id = ?", *cartID).Update("status", "paid").Error
+		case len(confirmed) > 0:
+			result = "captured, booking confirmed"
+		default:
+			result = "captured, booking no longer pending, refunded"
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, booking := range confirmed {
+		utils.SeatEvents.Publish(booking.ShowID, "booked", bookingSeatCodes(db, booking.ID))
+	}
+	for _, refund := range refunds {
+		settleRefund(db, refund)
+	}
+	return result, nil
+}
+
+// failPayment records a declined payment, and every share of a cart's payment,
+// so the customer can retry it.
+func failPayment(tx *gorm.DB, payments []models.Payment, reason string) (string, error) {
+	for _, payment := range payments {
+		if !utils.CanTransitionPayment(payment.Status, utils.PaymentFailed) {
+			return "ignored, payment is " + payment.Status, nil
+		}
+	}
+	for i := range payments {
+		if err := utils.TransitionPayment(tx, &payments[i], utils.PaymentFailed, "declined: "+reason, nil); err != nil {
+			return "", err
+		}
+	}
+	return "payment failed", nil
+}
+
+// PaymentWebhook receives payment notifications from a gateway. It is the only way
+// a booking becomes confirmed: the request must carry a valid gateway signature,
+// and each event is applied once however often it is delivered.
+func PaymentWebhook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gateway, ok := utils.PaymentGatewayByName(c.Param("gateway"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment gateway"})
+			return
+		}
+
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		event, err := gateway.VerifyWebhook(payload, c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		record := models.PaymentWebhookEvent{
+			Gateway:  gateway.Name(),
+			EventID:  event.ID,
+			Type:     event.Type,
+			IntentID: event.IntentID,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusOK, gin.H{"message": "Event already processed"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record event"})
+			return
+		}
+
+		var payments []models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("gateway = ? AND provider_tx = ?", gateway.Name(), event.IntentID).
+			Order("id").Find(&payments).Error; err != nil || len(payments) == 0 {
+			var topup models.WalletTopup
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("gateway = ? AND provider_tx = ?", gateway.Name(), event.IntentID).
+				First(&topup).Error; err != nil {
+				// Not committed yet or not ours; a non-2xx makes the gateway retry
+				tx.Rollback()
+				c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+				return
+			}
+			result, err := applyTopupEvent(tx, &topup, event)
+			if err == nil {
+				err = tx.Model(&record).Update("result", result).Error
+			}
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply wallet top-up"})
+				return
+			}
+			if err := tx.Commit().Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+				return
+			}
+
+			// The capture is recorded as under way before the gateway is asked for it
+			if topup.Status == utils.PaymentCapturing {
+				if result, err = captureTopup(db, gateway, &topup); err != nil {
+					// The gateway settler finishes it
+					c.JSON(http.StatusOK, gin.H{"message": "Event processed", "result": "capturing"})
+					return
+				}
+				db.Model(&record).Update("result", result)
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Event processed", "result": result})
+			return
+		}
+
+		var result string
+		switch event.Type {
+		case utils.WebhookPaymentAuthorized:
+			result, err = confirmAuthorizedPayment(tx, gateway, payments)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm booking"})
+				return
+			}
+		case utils.WebhookPaymentFailed:
+			result, err = failPayment(tx, payments, event.Reason)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment failure"})
+				return
+			}
+		default:
+			result = "ignored"
+		}
+
+		if err := tx.Model(&record).Update("result", result).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record event"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		// The capture is recorded as under way before the gateway is asked for it
+		if payments[0].Status == utils.PaymentCapturing {
+			if captured, err := capturePayments(db, gateway, payments); err == nil {
+				result = captured
+				db.Model(&record).Update("result", result)
+			}
+			// otherwise the gateway settler finishes it
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Event processed", "result": result})
+	}
+}
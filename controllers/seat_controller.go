@@ -2,13 +2,214 @@ package controllers
 
 import (
 	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// defaultSeatColumns is the row width used for screens that have no SeatLayout yet.
+const defaultSeatColumns = 12
+
+// rowLabel returns the spreadsheet-style label of the i-th row: A..Z, AA, AB...
+func rowLabel(i int) string {
+	label := ""
+	for i >= 0 {
+		label = string(rune('A'+i%26)) + label
+		i = i/26 - 1
+	}
+	return label
+}
+
+// defaultSeatLayout reproduces the original grid for screens without a layout:
+// rows of 12 seats labelled from A, stopping once seatsTotal seats exist.
+func defaultSeatLayout(seatsTotal int) models.SeatLayout {
+	var layout models.SeatLayout
+	for i := 0; seatsTotal > 0; i++ {
+		seats := defaultSeatColumns
+		if seatsTotal < seats {
+			seats = seatsTotal
+		}
+		layout.Rows = append(layout.Rows, models.SeatRow{Label: rowLabel(i), Seats: seats})
+		seatsTotal -= seats
+	}
+	return layout
+}
+
+// loadSeatLayout returns the layout of the show's screen, or the default grid
+// sized by show.SeatsTotal if the screen has none.
+func loadSeatLayout(db *gorm.DB, show *models.Show) (models.SeatLayout, error) {
+	return loadScreenLayout(db, show.ScreenID, show.SeatsTotal)
+}
+
+// loadScreenLayout returns the layout of a screen, or the default grid of seatsTotal seats.
+func loadScreenLayout(db *gorm.DB, screenID uint, seatsTotal int) (models.SeatLayout, error) {
+	var layout models.SeatLayout
+	err := db.Where("screen_id = ?", screenID).First(&layout).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultSeatLayout(seatsTotal), nil
+	}
+	return layout, err
+}
+
+// freeSeats returns the bookable seats of a show not taken by an active booking,
+// in layout order.
+func freeSeats(db *gorm.DB, show *models.Show, layout models.SeatLayout) ([]string, error) {
+	var taken []string
+	if err := db.Model(&models.BookingSeat{}).Where("show_id = ? AND active = ?", show.ID, true).Pluck("seat_code", &taken).Error; err != nil {
+		return nil, err
+	}
+	takenMap := make(map[string]bool)
+	for _, code := range taken {
+		takenMap[code] = true
+	}
+
+	var free []string
+	for _, row := range layout.Rows {
+		for pos := 1; pos <= row.Seats; pos++ {
+			if containsInt(row.Gaps, pos) || containsInt(row.Disabled, pos) {
+				continue
+			}
+			code := row.Label + strconv.Itoa(pos)
+			if !takenMap[code] {
+				free = append(free, code)
+			}
+		}
+	}
+	return free, nil
+}
+
+// seatPrice is the category and price a single seat of a show sells at.
+type seatPrice struct {
+	Category  string
+	Price     float64
+	BasePrice float64             // before the pricing rule
+	Rule      *models.PricingRule // the pricing rule applied, if any
+}
+
+// bookingSeat is a new active seat of a booking sold at this price.
+func (p seatPrice) bookingSeat(showID uint, code string, now time.Time) models.BookingSeat {
+	seat := models.BookingSeat{
+		ShowID:    showID,
+		SeatCode:  code,
+		Price:     p.Price,
+		BasePrice: p.BasePrice,
+		Category:  p.Category,
+		Active:    true,
+		CreatedAt: now,
+	}
+	if p.Rule != nil {
+		seat.PricingRuleID = &p.Rule.ID
+		seat.PricingRule = p.Rule.Name
+	}
+	return seat
+}
+
+// loadPriceOverrides returns the per-show category prices of a show, keyed by category ID.
+func loadPriceOverrides(db *gorm.DB, showID uint) (map[uint]float64, error) {
+	var overrides []models.ShowCategoryPrice
+	if err := db.Where("show_id = ?", showID).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	overrideMap := make(map[uint]float64)
+	for _, o := range overrides {
+		overrideMap[o.SeatCategoryID] = o.Price
+	}
+	return overrideMap, nil
+}
+
+// categoryPrice is what seats of a category sell at for a show.
+func categoryPrice(show *models.Show, category models.SeatCategory, overrides map[uint]float64) float64 {
+	if override, ok := overrides[category.ID]; ok {
+		return override
+	}
+	if category.Price > 0 {
+		return category.Price
+	}
+	return show.Price
+}
+
+// loadSeatPrices prices every seat of the layout for a show booked now. A per-show
+// override beats the category's own price, which beats Show.Price; seats listed
+// individually in a category beat the category of their row. The pricing rule that
+// holds for the show right now then adjusts every base price.
+func loadSeatPrices(db *gorm.DB, show *models.Show, layout models.SeatLayout) (map[string]seatPrice, error) {
+	rules, err := utils.LoadPricingRules(db, show)
+	if err != nil {
+		return nil, err
+	}
+	rule := utils.MatchPricingRule(rules, show, time.Now())
+
+	var categories []models.SeatCategory
+	if err := db.Where("screen_id = ?", show.ScreenID).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	overrides, err := loadPriceOverrides(db, show.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byRow := make(map[string]seatPrice)
+	bySeat := make(map[string]seatPrice)
+	for _, category := range categories {
+		p := seatPrice{Category: category.Name, Price: categoryPrice(show, category, overrides)}
+		for _, row := range category.Rows {
+			byRow[row] = p
+		}
+		for _, seat := range category.Seats {
+			bySeat[seat] = p
+		}
+	}
+
+	prices := make(map[string]seatPrice)
+	for _, row := range layout.Rows {
+		for pos := 1; pos <= row.Seats; pos++ {
+			code := row.Label + strconv.Itoa(pos)
+			p, ok := bySeat[code]
+			if !ok {
+				if p, ok = byRow[row.Label]; !ok {
+					p = seatPrice{Price: show.Price}
+				}
+			}
+			p.BasePrice = p.Price
+			p.Price = utils.ApplyPricingRule(rule, p.Price)
+			p.Rule = rule
+			prices[code] = p
+		}
+	}
+	return prices, nil
+}
+
+// containsInt reports whether n is in list.
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// bookableSeats returns the codes of every seat in the layout that can be sold.
+func bookableSeats(layout models.SeatLayout) map[string]bool {
+	seats := make(map[string]bool)
+	for _, row := range layout.Rows {
+		for pos := 1; pos <= row.Seats; pos++ {
+			if containsInt(row.Gaps, pos) || containsInt(row.Disabled, pos) {
+				continue
+			}
+			seats[row.Label+strconv.Itoa(pos)] = true
+		}
+	}
+	return seats
+}
+
 func GetShowSeats(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		showID, err := strconv.Atoi(c.Param("id"))
@@ -24,60 +225,138 @@ func GetShowSeats(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		//  Fetch all booked seats for this show (NOTE: All seats are fetched here regardless of booking status for seat map display)
-		var bookedSeats []models.BookingSeat
-		if err := db.Where("show_id = ?", showID).Find(&bookedSeats).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booked seats"})
+		seatLayout, err := loadSeatLayout(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
 			return
 		}
 
-		//  Build booked seat map for fast lookup
-		bookedMap := make(map[string]bool)
-		for _, s := range bookedSeats {
-			bookedMap[s.SeatCode] = true
+		prices, err := loadSeatPrices(db, &show, seatLayout)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat prices"})
+			return
 		}
 
-		// Build seat layout dynamically: UNIFIED LOGIC
-		// Use a consistent, large naming convention (A-J, 1-12 max) and stop at show.SeatsTotal.
-		rows := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
-		maxColsPerRow := 12
-		layout := []map[string]interface{}{}
-
-		seatIndex := 0
-		seatsToGenerate := show.SeatsTotal // Use the actual total seats from the show model
+		//  Fetch seats taken by confirmed bookings or live holds (expired and cancelled bookings free their seats,
+		//  and seats exchanged, moved or refunded out of a booking are no longer active)
+		var takenSeats []struct {
+			SeatCode string
+			Status   string
+		}
+		if err := db.Table("booking_seats").
+			Select("booking_seats.seat_code, bookings.status").
+			Joins("JOIN bookings ON bookings.id = booking_seats.booking_id").
+			Where("booking_seats.show_id = ? AND booking_seats.active = ?", showID, true).
+			Where(activeBookingClause, time.Now()).
+			Scan(&takenSeats).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch booked seats"})
+			return
+		}
 
-		for _, row := range rows {
-			if seatIndex >= seatsToGenerate {
-				break // Stop generating rows if capacity is reached
+		//  Build seat status map for fast lookup: "booked" once paid, "held" while pending
+		statusMap := make(map[string]string)
+		for _, s := range takenSeats {
+			if s.Status == "confirmed" {
+				statusMap[s.SeatCode] = "booked"
+			} else {
+				statusMap[s.SeatCode] = "held"
 			}
+		}
+
+		// Walk the layout position by position; gaps are emitted so clients can draw them
+		layout := []map[string]interface{}{}
+		for _, row := range seatLayout.Rows {
 			rowSeats := []map[string]interface{}{}
-			for i := 1; i <= maxColsPerRow; i++ {
-				if seatIndex >= seatsToGenerate {
-					break // Stop generating seats in this row if capacity is reached
+			for pos := 1; pos <= row.Seats; pos++ {
+				if containsInt(row.Gaps, pos) {
+					rowSeats = append(rowSeats, gin.H{
+						"seat_code": "",
+						"number":    pos,
+						"status":    "gap",
+					})
+					continue
 				}
 
-				// Generate seat code using the new convention (e.g., A1, A2, B1...)
-				code := row + strconv.Itoa(i)
+				code := row.Label + strconv.Itoa(pos)
 				status := "available"
-				if bookedMap[code] {
-					status = "booked"
+				if containsInt(row.Disabled, pos) {
+					status = "disabled"
+				} else if taken, ok := statusMap[code]; ok {
+					status = taken
 				}
 
 				rowSeats = append(rowSeats, gin.H{
-					"seat_code": code,
-					"status":    status,
-					"price":     show.Price,
+					"seat_code":  code,
+					"number":     pos,
+					"status":     status,
+					"category":   prices[code].Category,
+					"price":      prices[code].Price,
+					"base_price": prices[code].BasePrice,
 				})
-				seatIndex++ // Increment the counter for every seat generated
 			}
-			layout = append(layout, gin.H{"row": row, "seats": rowSeats})
+			layout = append(layout, gin.H{"row": row.Label, "aisles": row.Aisles, "seats": rowSeats})
+		}
+
+		// Every seat is priced under the same rule
+		var pricingRule interface{}
+		for _, p := range prices {
+			if p.Rule != nil {
+				pricingRule = gin.H{"id": p.Rule.ID, "name": p.Rule.Name}
+				break
+			}
 		}
 
 		// Return the layout
 		c.JSON(http.StatusOK, gin.H{
-			"show_id":     show.ID,
-			"price":       show.Price,
-			"seat_layout": layout,
+			"show_id":      show.ID,
+			"price":        show.Price,
+			"pricing_rule": pricingRule,
+			"seat_layout":  layout,
+		})
+	}
+}
+
+// StreamShowSeats pushes seat status changes of a show to the client as
+// Server-Sent Events ("seats" events carrying a utils.SeatEvent), so an open
+// seat map can update without polling GetShowSeats.
+func StreamShowSeats(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		showID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID"})
+			return
+		}
+
+		var show models.Show
+		if err := db.First(&show, showID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+
+		events, unsubscribe := utils.SeatEvents.Subscribe(show.ID)
+		defer unsubscribe()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // keep reverse proxies from buffering the stream
+
+		// periodic pings keep idle connections from being dropped by proxies
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				c.SSEvent("seats", event)
+				return true
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now().Unix())
+				return true
+			}
 		})
 	}
 }
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultMovieMinutes = 180              // used when a movie has no usable duration
+	checkInOpensBefore  = 60 * time.Minute // gates open this long before the show
+	ticketQRSize        = 256
+)
+
+// showEndTime estimates when a show ends from its movie's duration.
+func showEndTime(show *models.Show) time.Time {
+	minutes, err := strconv.Atoi(strings.TrimSpace(show.Movie.DurationMin))
+	if err != nil || minutes <= 0 {
+		minutes = defaultMovieMinutes
+	}
+	return show.StartTime.Add(time.Duration(minutes) * time.Minute)
+}
+
+// loadTicketBooking fetches a confirmed booking of the user together with what a ticket needs.
+func loadTicketBooking(db *gorm.DB, id string, userID uint) (*models.Booking, int, string) {
+	var booking models.Booking
+	if err := db.Preload("Seats", "active = ?", true).Preload("Show.Movie").
+		Preload("FoodOrders", "status <> ?", "cancelled").Preload("FoodOrders.Items").
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&booking).Error; err != nil {
+		return nil, http.StatusNotFound, "Booking not found"
+	}
+	if booking.Status != "confirmed" {
+		return nil, http.StatusConflict, "Tickets are only issued for confirmed bookings"
+	}
+	return &booking, 0, ""
+}
+
+// issueTicket signs a ticket for the booking's current seats, valid until the show ends.
+func issueTicket(booking *models.Booking) (string, error) {
+	var seats []string
+	for _, seat := range booking.Seats {
+		seats = append(seats, seat.SeatCode)
+	}
+	return utils.CreateTicketToken(booking.ID, booking.ShowID, seats, booking.TicketVersion, showEndTime(&booking.Show))
+}
+
+// GetBookingTicket returns the signed e-ticket of a confirmed booking.
+func GetBookingTicket(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, status, msg := loadTicketBooking(db, c.Param("id"), c.GetUint("userId"))
+		if booking == nil {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+
+		token, err := issueTicket(booking)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"booking_id": booking.ID,
+			"show_id":    booking.ShowID,
+			"movie":      booking.Show.Movie.Title,
+			"start_time": booking.Show.StartTime,
+			"seats":      booking.Seats,
+			"food":       booking.FoodOrders, // collected at the counter with their pickup codes
+			"token":      token,
+		})
+	}
+}
+
+// GetBookingTicketQR renders the e-ticket of a confirmed booking as a QR code PNG.
+func GetBookingTicketQR(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, status, msg := loadTicketBooking(db, c.Param("id"), c.GetUint("userId"))
+		if booking == nil {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+
+		token, err := issueTicket(booking)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+			return
+		}
+
+		png, err := utils.TicketQRCode(token, ticketQRSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render ticket QR code"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "image/png", png)
+	}
+}
+
+// CheckInTicket — Staff: validate a scanned e-ticket and admit its seats. Each seat
+// can be admitted once; scanning the same ticket again is rejected.
+func CheckInTicket(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || body.Token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ticket token is required"})
+			return
+		}
+
+		claims, err := utils.ValidateTicketToken(body.Token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, claims.BookingID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if err := tx.Preload("Seats", "active = ?", true).Preload("Show.Movie").Preload("User").First(&booking, booking.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking"})
+			return
+		}
+
+		if booking.Status != "confirmed" || booking.ShowID != claims.ShowID {
+			tx.Rollback()
+			c.JSON(http.StatusForbidden, gin.H{"error": "Ticket is no longer valid for this booking"})
+			return
+		}
+		if claims.Version != booking.TicketVersion {
+			// Reissued after a transfer; only the newest ticket admits
+			tx.Rollback()
+			c.JSON(http.StatusForbidden, gin.H{"error": "Ticket has been replaced by a newer one"})
+			return
+		}
+
+		now := time.Now()
+		if now.Before(booking.Show.StartTime.Add(-checkInOpensBefore)) || now.After(showEndTime(&booking.Show)) {
+			tx.Rollback()
+			c.JSON(http.StatusForbidden, gin.H{"error": "Check-in is not open for this show", "start_time": booking.Show.StartTime})
+			return
+		}
+
+		// Every seat on the ticket must still belong to the booking (seat exchanges void old tickets)
+		seatMap := make(map[string]models.BookingSeat)
+		for _, seat := range booking.Seats {
+			seatMap[seat.SeatCode] = seat
+		}
+		var seatIDs []uint
+		for _, code := range claims.Seats {
+			seat, ok := seatMap[code]
+			if !ok {
+				tx.Rollback()
+				c.JSON(http.StatusForbidden, gin.H{"error": "Seat " + code + " is no longer part of this booking"})
+				return
+			}
+			if seat.AdmittedAt != nil {
+				tx.Rollback()
+				c.JSON(http.StatusConflict, gin.H{"error": "Ticket already used", "seat_code": code, "admitted_at": seat.AdmittedAt})
+				return
+			}
+			seatIDs = append(seatIDs, seat.ID)
+		}
+		if len(seatIDs) == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusForbidden, gin.H{"error": "Ticket has no seats"})
+			return
+		}
+
+		if err := tx.Model(&models.BookingSeat{}).Where("id IN ?", seatIDs).Update("admitted_at", now).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to admit seats"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Admitted",
+			"booking_id":  booking.ID,
+			"customer":    booking.User.FullName,
+			"movie":       booking.Show.Movie.Title,
+			"start_time":  booking.Show.StartTime,
+			"seats":       claims.Seats,
+			"admitted_at": now,
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cineverse/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transferredBooking reports whether seats of the booking changed hands. Such
+// bookings keep their tickets but can no longer be cancelled, exchanged or added
+// to, as the money paid for them belongs to someone else.
+func transferredBooking(db *gorm.DB, bookingID uint) bool {
+	var count int64
+	db.Model(&models.BookingTransfer{}).
+		Where("status = ? AND (booking_id = ? OR new_booking_id = ?)", "accepted", bookingID, bookingID).
+		Count(&count)
+	return count > 0
+}
+
+// bookingPayer returns the user who paid for the booking: whoever handed the
+// whole booking over first, or its holder when it never changed hands.
+func bookingPayer(db *gorm.DB, bookingID uint) (uint, error) {
+	var transfer models.BookingTransfer
+	err := db.Where("booking_id = ? AND whole_booking = ? AND status = ?", bookingID, true, "accepted").
+		Order("id").First(&transfer).Error
+	if err == nil {
+		return transfer.FromUserID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	var booking models.Booking
+	if err := db.Select("id", "user_id").First(&booking, bookingID).Error; err != nil {
+		return 0, err
+	}
+	return booking.UserID, nil
+}
+
+// cancelTransferredBookings cancels the confirmed bookings that received seats
+// out of the source booking, which go with it as it paid for them. It returns
+// the seats it let go of; the caller publishes them once committed.
+func cancelTransferredBookings(tx *gorm.DB, sourceID uint) ([]string, error) {
+	var children []models.Booking
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("source_booking_id = ? AND status = ?", sourceID, "confirmed").
+		Find(&children).Error; err != nil {
+		return nil, err
+	}
+	var released []string
+	for _, child := range children {
+		codes := bookingSeatCodes(tx, child.ID)
+		if err := tx.Model(&child).Updates(map[string]interface{}{
+			"status":     "cancelled",
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ? AND active = ?", child.ID, true).
+			Update("active", false).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.Show{}).Where("id = ? AND seats_booked >= ?", child.ShowID, child.SeatsCount).
+			Update("seats_booked", gorm.Expr("seats_booked - ?", child.SeatsCount)).Error; err != nil {
+			return nil, err
+		}
+		released = append(released, codes...)
+	}
+	return released, nil
+}
+
+// transferableSeats picks the booking's seats a transfer covers, all of them when
+// codes is empty. Seats already admitted at the gate cannot be given away.
+func transferableSeats(db *gorm.DB, bookingID uint, codes []string) ([]models.BookingSeat, string) {
+	var seats []models.BookingSeat
+	query := db.Where("booking_id = ? AND active = ?", bookingID, true)
+	if len(codes) > 0 {
+		query = query.Where("seat_code IN ?", codes)
+	}
+	if err := query.Order("seat_code").Find(&seats).Error; err != nil {
+		return nil, "Failed to load seats"
+	}
+	if len(seats) == 0 || (len(codes) > 0 && len(seats) != len(codes)) {
+		return nil, "Some of these seats are not part of the booking"
+	}
+	for _, seat := range seats {
+		if seat.AdmittedAt != nil {
+			return nil, "Seat " + seat.SeatCode + " has already been admitted"
+		}
+	}
+	return seats, ""
+}
+
+// CreateBookingTransfer — User: offer seats of a confirmed booking, or the whole
+// booking, to another registered user by email. The recipient has until the show
+// starts to accept; the booking has one open offer at a time.
+func CreateBookingTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var req struct {
+			Email     string   `json:"email"`
+			SeatCodes []string `json:"seat_codes"` // optional; defaults to every seat
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer data"})
+			return
+		}
+		seatCodes, ok := normalizeSeatCodes(req.SeatCodes)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate seat codes in request"})
+			return
+		}
+
+		var booking models.Booking
+		if err := db.Preload("Show").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&booking).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if booking.Status != "confirmed" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only confirmed bookings can be transferred"})
+			return
+		}
+		if !booking.Show.StartTime.After(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The show has already started"})
+			return
+		}
+
+		var recipient models.User
+		if err := db.Where("email = ? AND blocked = ?", strings.TrimSpace(req.Email), false).First(&recipient).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No registered user with that email"})
+			return
+		}
+		if recipient.ID == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot transfer a booking to yourself"})
+			return
+		}
+
+		seats, msg := transferableSeats(db, booking.ID, seatCodes)
+		if seats == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+		var activeSeats int64
+		if err := db.Model(&models.BookingSeat{}).Where("booking_id = ? AND active = ?", booking.ID, true).Count(&activeSeats).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seats"})
+			return
+		}
+
+		var open int64
+		if err := db.Model(&models.BookingTransfer{}).Where("booking_id = ? AND status = ?", booking.ID, "offered").Count(&open).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check transfers"})
+			return
+		}
+		if open > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "This booking already has an open transfer; cancel it first"})
+			return
+		}
+
+		transfer := models.BookingTransfer{
+			BookingID:    booking.ID,
+			ShowID:       booking.ShowID,
+			FromUserID:   userID,
+			ToUserID:     recipient.ID,
+			Email:        recipient.Email,
+			WholeBooking: len(seats) == int(activeSeats),
+			Status:       "offered",
+		}
+		for _, seat := range seats {
+			transfer.SeatCodes = append(transfer.SeatCodes, seat.SeatCode)
+		}
+		if err := db.Create(&transfer).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Transfer offered", "transfer": transfer})
+	}
+}
+
+// GetUserTransfers — User: the transfers they have sent and received, newest first.
+func GetUserTransfers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var transfers []models.BookingTransfer
+		if err := db.Preload("Show.Movie").
+			Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+			Order("created_at desc").Find(&transfers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfers"})
+			return
+		}
+
+		incoming := make([]models.BookingTransfer, 0)
+		outgoing := make([]models.BookingTransfer, 0)
+		for _, transfer := range transfers {
+			if transfer.ToUserID == userID {
+				incoming = append(incoming, transfer)
+			} else {
+				outgoing = append(outgoing, transfer)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"incoming": incoming, "outgoing": outgoing})
+	}
+}
+
+// AcceptBookingTransfer — User: take the seats offered to them. A whole booking
+// becomes theirs; some seats move to a new confirmed booking of their own. Either
+// way the sender's tickets stop admitting and the recipient gets a fresh one.
+func AcceptBookingTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		var transfer models.BookingTransfer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND to_user_id = ?", c.Param("id"), userID).
+			First(&transfer).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+			return
+		}
+		if transfer.Status != "offered" {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "This transfer is no longer open", "status": transfer.Status})
+			return
+		}
+
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, transfer.BookingID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+			return
+		}
+		if err := tx.Preload("Show").First(&booking, booking.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking"})
+			return
+		}
+		now := time.Now()
+		if booking.Status != "confirmed" || booking.UserID != transfer.FromUserID {
+			tx.Rollback()
+			c.JSON(http.StatusGone, gin.H{"error": "The booking can no longer be transferred"})
+			return
+		}
+		if !booking.Show.StartTime.After(now) {
+			tx.Rollback()
+			c.JSON(http.StatusGone, gin.H{"error": "The show has already started"})
+			return
+		}
+
+		// The seats may have been exchanged or admitted since the offer
+		seats, msg := transferableSeats(tx, booking.ID, transfer.SeatCodes)
+		if seats == nil {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+		var activeSeats int64
+		if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ? AND active = ?", booking.ID, true).Count(&activeSeats).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seats"})
+			return
+		}
+		if transfer.WholeBooking != (len(seats) == int(activeSeats)) {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "The booking's seats changed since the transfer was offered"})
+			return
+		}
+
+		received := booking
+		if transfer.WholeBooking {
+			// Hand the booking over; its food orders go with it
+			if err := tx.Model(&booking).Updates(map[string]interface{}{
+				"user_id":        userID,
+				"ticket_version": gorm.Expr("ticket_version + 1"),
+			}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer booking"})
+				return
+			}
+		} else {
+			received = models.Booking{
+				UserID:          userID,
+				ShowID:          booking.ShowID,
+				SeatsCount:      len(seats),
+				Status:          "confirmed",
+				PaymentMethod:   "transfer",
+				SourceBookingID: &booking.ID,
+			}
+			if err := tx.Create(&received).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+				return
+			}
+			if err := tx.Model(&models.BookingSeat{}).
+				Where("booking_id = ? AND active = ? AND seat_code IN ?", booking.ID, true, transfer.SeatCodes).
+				Update("booking_id", received.ID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move seats"})
+				return
+			}
+			if err := tx.Model(&booking).Updates(map[string]interface{}{
+				"seats_count":    gorm.Expr("seats_count - ?", len(seats)),
+				"ticket_version": gorm.Expr("ticket_version + 1"),
+			}).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+				return
+			}
+		}
+
+		if err := tx.Model(&transfer).Updates(map[string]interface{}{
+			"status":         "accepted",
+			"new_booking_id": received.ID,
+			"responded_at":   now,
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+			return
+		}
+
+		if err := tx.Preload("Seats", "active = ?", true).Preload("Show.Movie").First(&received, received.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load booking"})
+			return
+		}
+		token, err := issueTicket(&received)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Transfer accepted",
+			"booking": received,
+			"token":   token,
+		})
+	}
+}
+
+// respondToTransfer closes an open transfer on behalf of its sender or recipient.
+func respondToTransfer(c *gin.Context, db *gorm.DB, party, status, message string) {
+	result := db.Model(&models.BookingTransfer{}).
+		Where("id = ? AND "+party+" = ? AND status = ?", c.Param("id"), c.GetUint("userId"), "offered").
+		Updates(map[string]interface{}{"status": status, "responded_at": time.Now()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Open transfer not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// DeclineBookingTransfer — User: turn down seats offered to them. The sender keeps them.
+func DeclineBookingTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondToTransfer(c, db, "to_user_id", "declined", "Transfer declined")
+	}
+}
+
+// CancelBookingTransfer — User: withdraw a transfer they offered before it is accepted.
+func CancelBookingTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondToTransfer(c, db, "from_user_id", "cancelled", "Transfer cancelled")
+	}
+}
@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"cineverse/models"
+	"cineverse/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxWaitlistSeats = 10
+
+// waitlistOffer is a set of seats held for a waitlisted user, for seat map events.
+type waitlistOffer struct {
+	showID uint
+	seats  []string
+}
+
+// offerSeatsToWaitlist expires lapsed holds of a show and hands the seats they
+// free to its waitlist, in one transaction. The dispatcher runs it for shows the
+// seat hold sweeper leaves alone.
+func offerSeatsToWaitlist(db *gorm.DB, showID uint) error {
+	var lapsed []string
+	var offers []waitlistOffer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		lapsed, offers, err = releaseLapsedHolds(tx, showID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.SeatEvents.Publish(showID, "released", lapsed)
+	publishWaitlistOffers(offers)
+	return nil
+}
+
+// releaseLapsedHolds expires the lapsed holds of a show inside the caller's
+// transaction and offers the seats they free to the waitlist before anyone else
+// can book them. The caller publishes both once it has committed.
+func releaseLapsedHolds(tx *gorm.DB, showID uint) ([]string, []waitlistOffer, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, showID).Error; err != nil {
+		return nil, nil, err
+	}
+	lapsed, err := utils.ExpireStaleHoldsForShow(tx, showID)
+	if err != nil {
+		return nil, nil, err
+	}
+	offers, err := offerWaitlistSeats(tx, showID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lapsed, offers, nil
+}
+
+// offerWaitlistSeats settles offers that were paid for or lapsed and then hands
+// free seats of the show to waiting users, oldest first. Each offer is a pending
+// booking whose hold lasts utils.WaitlistOfferTTL; when it lapses the seats are
+// released again and move on to the next user in line.
+//
+// Every path that frees seats calls it in the transaction that frees them, so
+// the head of the queue is served before the seats become publicly bookable.
+// The returned offers are published with publishWaitlistOffers after commit.
+func offerWaitlistSeats(tx *gorm.DB, showID uint) ([]waitlistOffer, error) {
+	var show models.Show
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&show, showID).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var offered []models.WaitlistEntry
+	if err := tx.Where("show_id = ? AND status = ?", show.ID, "offered").Find(&offered).Error; err != nil {
+		return nil, err
+	}
+	for _, entry := range offered {
+		status := "lapsed"
+		var booking models.Booking
+		if entry.BookingID != nil && tx.First(&booking, *entry.BookingID).Error == nil {
+			switch booking.Status {
+			case "pending":
+				continue
+			case "confirmed":
+				status = "fulfilled"
+			}
+		}
+		if err := tx.Model(&entry).Update("status", status).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if !show.StartTime.After(now) {
+		return nil, tx.Model(&models.WaitlistEntry{}).
+			Where("show_id = ? AND status = ?", show.ID, "waiting").
+			Update("status", "lapsed").Error
+	}
+
+	var waiting []models.WaitlistEntry
+	if err := tx.Where("show_id = ? AND status = ?", show.ID, "waiting").Order("created_at ASC, id ASC").Find(&waiting).Error; err != nil {
+		return nil, err
+	}
+	if len(waiting) == 0 {
+		return nil, nil
+	}
+
+	seatLayout, err := loadSeatLayout(tx, &show)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := loadSeatPrices(tx, &show, seatLayout)
+	if err != nil {
+		return nil, err
+	}
+	free, err := freeSeats(tx, &show, seatLayout)
+	if err != nil {
+		return nil, err
+	}
+	taxFees, err := utils.LoadTaxFees(tx, &show)
+	if err != nil {
+		return nil, err
+	}
+
+	var offers []waitlistOffer
+	for _, entry := range waiting {
+		// Strict queue order: a later, smaller request never jumps the line
+		if entry.SeatsWanted > len(free) {
+			break
+		}
+		seatCodes := free[:entry.SeatsWanted]
+		free = free[entry.SeatsWanted:]
+
+		var seats []models.BookingSeat
+		var total float64
+		for _, code := range seatCodes {
+			seats = append(seats, prices[code].bookingSeat(show.ID, code, now))
+			total += prices[code].Price
+		}
+
+		charges := utils.ComputeCharges(taxFees, utils.ChargeBase{Tickets: utils.RoundMoney(total), Seats: len(seats)})
+
+		expiresAt := now.Add(utils.WaitlistOfferTTL())
+		booking := models.Booking{
+			UserID:      entry.UserID,
+			ShowID:      show.ID,
+			SeatsCount:  len(seats),
+			TotalAmount: utils.RoundMoney(total + charges.Added()),
+			Fees:        charges.Fees,
+			Taxes:       charges.Taxes,
+			Status:      "pending",
+			CreatedAt:   now,
+			ExpiresAt:   &expiresAt,
+			Seats:       seats,
+		}
+		if err := tx.Create(&booking).Error; err != nil {
+			return nil, err
+		}
+		if err := utils.SaveCharges(tx, booking.ID, charges); err != nil {
+			return nil, err
+		}
+
+		if err := tx.Model(&entry).Updates(map[string]interface{}{
+			"status":     "offered",
+			"booking_id": booking.ID,
+			"offered_at": now,
+		}).Error; err != nil {
+			return nil, err
+		}
+		offers = append(offers, waitlistOffer{showID: show.ID, seats: seatCodes})
+	}
+	return offers, nil
+}
+
+// publishWaitlistOffers tells live seat maps about seats held for waitlisted users.
+func publishWaitlistOffers(offers []waitlistOffer) {
+	for _, offer := range offers {
+		utils.SeatEvents.Publish(offer.showID, "held", offer.seats)
+	}
+}
+
+// StartWaitlistDispatcher re-checks every show with an open waitlist each
+// interval, so offers that lapsed move on to the next user in line. Seats freed
+// by customers and admins are offered by the transaction that frees them.
+func StartWaitlistDispatcher(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			var showIDs []uint
+			if err := db.Model(&models.WaitlistEntry{}).
+				Where("status IN ?", []string{"waiting", "offered"}).
+				Distinct().Pluck("show_id", &showIDs).Error; err != nil {
+				log.Printf("waitlist: failed to list shows: %v", err)
+				continue
+			}
+			for _, showID := range showIDs {
+				if err := offerSeatsToWaitlist(db, showID); err != nil {
+					log.Printf("waitlist: failed to offer seats for show %d: %v", showID, err)
+				}
+			}
+		}
+	}()
+}
+
+func JoinWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		showID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID"})
+			return
+		}
+
+		var body struct {
+			Seats int `json:"seats"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || body.Seats <= 0 || body.Seats > maxWaitlistSeats {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Seats must be between 1 and " + strconv.Itoa(maxWaitlistSeats)})
+			return
+		}
+
+		var show models.Show
+		if err := db.First(&show, showID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+			return
+		}
+		if !show.StartTime.After(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The show has already started"})
+			return
+		}
+
+		seatLayout, err := loadSeatLayout(db, &show)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seat layout"})
+			return
+		}
+		free, err := freeSeats(db, &show, seatLayout)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check seat availability"})
+			return
+		}
+		if len(free) >= body.Seats {
+			c.JSON(http.StatusConflict, gin.H{"error": "Seats are available, book them directly", "available_seats": len(free)})
+			return
+		}
+
+		var existing models.WaitlistEntry
+		err = db.Where("show_id = ? AND user_id = ? AND status IN ?", show.ID, userID, []string{"waiting", "offered"}).First(&existing).Error
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already on the waitlist for this show"})
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check waitlist"})
+			return
+		}
+
+		entry := models.WaitlistEntry{
+			ShowID:      show.ID,
+			UserID:      userID,
+			SeatsWanted: body.Seats,
+			Status:      "waiting",
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
+			return
+		}
+
+		var position int64
+		db.Model(&models.WaitlistEntry{}).
+			Where("show_id = ? AND status = ? AND id <= ?", show.ID, "waiting", entry.ID).
+			Count(&position)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Added to waitlist",
+			"entry":    entry,
+			"position": position,
+		})
+	}
+}
+
+func GetUserWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var entries []models.WaitlistEntry
+		if err := db.Preload("Show.Movie").
+			Where("user_id = ?", userID).
+			Order("created_at desc").
+			Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// LeaveWaitlist removes the user from a waitlist, giving up any seats currently offered.
+func LeaveWaitlist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("userId")
+
+		var entry models.WaitlistEntry
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&entry).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+			return
+		}
+		if entry.Status != "waiting" && entry.Status != "offered" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Waitlist entry is already " + entry.Status})
+			return
+		}
+
+		wasOffered := entry.Status == "offered"
+		var released []string
+		var offers []waitlistOffer
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Show{}, entry.ShowID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&entry).Update("status", "cancelled").Error; err != nil {
+				return err
+			}
+			if !wasOffered || entry.BookingID == nil {
+				return nil
+			}
+
+			result := tx.Model(&models.Booking{}).
+				Where("id = ? AND status = ?", *entry.BookingID, "pending").
+				Update("status", "cancelled")
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			released = bookingSeatCodes(tx, *entry.BookingID)
+			if err := utils.CancelFoodOrders(tx, []uint{*entry.BookingID}); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.BookingSeat{}).Where("booking_id = ?", *entry.BookingID).Update("active", false).Error; err != nil {
+				return err
+			}
+			// The seats go to the next user in line
+			var err error
+			offers, err = offerWaitlistSeats(tx, entry.ShowID)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+			return
+		}
+		utils.SeatEvents.Publish(entry.ShowID, "released", released)
+		publishWaitlistOffers(offers)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Removed from waitlist"})
+	}
+}
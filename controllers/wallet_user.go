@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cineverse/models"
+	"cineverse/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// payFromWallet pays a pending booking in full from its owner's wallet and
+// confirms it. A failed earlier payment of the booking is reused, as retryPayment
+// does; otherwise a new payment is recorded. Wallet payments need no webhook, so
+// the payment runs through its whole lifecycle at once. It fails with
+// utils.ErrInsufficientBalance if the wallet does not hold enough.
+func payFromWallet(tx *gorm.DB, booking *models.Booking, failed *models.Payment) (*models.Payment, error) {
+	entry, err := utils.MoveFromWallet(tx, booking.UserID, utils.LedgerSales, booking.TotalAmount, models.LedgerTransaction{
+		Type:      utils.LedgerPayment,
+		Reference: fmt.Sprintf("booking-%d", booking.ID),
+		BookingID: &booking.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ref := fmt.Sprintf("ledger:%d", entry.ID)
+
+	payment := failed
+	if payment == nil {
+		payment = &models.Payment{
+			BookingID:  booking.ID,
+			Amount:     booking.TotalAmount,
+			Method:     utils.WalletGateway,
+			Gateway:    utils.WalletGateway,
+			ProviderTx: ref,
+			Status:     utils.PaymentInitiated,
+			Attempts:   1,
+		}
+		if err := tx.Create(payment).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.PaymentTransition{PaymentID: payment.ID, ToStatus: utils.PaymentInitiated, Reason: "payment initiated"}).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		if err := utils.TransitionPayment(tx, payment, utils.PaymentInitiated, fmt.Sprintf("retry, attempt %d", payment.Attempts+1), map[string]interface{}{
+			"amount":      booking.TotalAmount,
+			"method":      utils.WalletGateway,
+			"gateway":     utils.WalletGateway,
+			"provider_tx": ref,
+			"attempts":    gorm.Expr("attempts + 1"),
+		}); err != nil {
+			return nil, err
+		}
+		payment.Amount, payment.Method, payment.Gateway, payment.ProviderTx = booking.TotalAmount, utils.WalletGateway, utils.WalletGateway, ref
+		payment.Attempts++
+	}
+
+	if err := utils.TransitionPayment(tx, payment, utils.PaymentAuthorized, "paid from wallet", nil); err != nil {
+		return nil, err
+	}
+	if err := utils.TransitionPayment(tx, payment, utils.PaymentCaptured, "captured", nil); err != nil {
+		return nil, err
+	}
+	return payment, utils.ConfirmPaidBooking(tx, booking)
+}
+
+// walletCheckout is InitiatePayment for the "wallet" method: the caller's own
+// booking is paid and confirmed in one go.
+func walletCheckout(c *gin.Context, db *gorm.DB, bookingID uint) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The show first, then the booking, as cancellations and refunds lock them
+	if err := lockBookingShow(tx, bookingID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock show"})
+		return
+	}
+	var booking models.Booking
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", c.GetUint("userId")).First(&booking, bookingID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	if status, msg := checkPayable(&booking); status != 0 {
+		tx.Rollback()
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	var failed *models.Payment
+	var existing models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_id = ?", booking.ID).First(&existing).Error; err == nil {
+		if existing.Status != utils.PaymentFailed {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment already initiated for this booking", "payment_id": existing.ID, "status": existing.Status})
+			return
+		}
+		failed = &existing
+	}
+
+	payment, err := payFromWallet(tx, &booking, failed)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, utils.ErrInsufficientBalance) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough wallet balance: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pay from wallet"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+	utils.SeatEvents.Publish(booking.ShowID, "booked", bookingSeatCodes(db, booking.ID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Paid from wallet, booking confirmed",
+		"payment_id": payment.ID,
+		"gateway":    payment.Gateway,
+		"status":     payment.Status,
+		"attempts":   payment.Attempts,
+	})
+}
+
+// applyTopupEvent marks a wallet top-up for capture once the gateway authorizes
+// it, or records its failure. It returns a short outcome for the webhook log; a
+// top-up left capturing is captured by captureTopup after the commit.
+func applyTopupEvent(tx *gorm.DB, topup *models.WalletTopup, event *utils.WebhookEvent) (string, error) {
+	if topup.Status != utils.PaymentInitiated {
+		return "ignored, top-up is " + topup.Status, nil
+	}
+
+	switch event.Type {
+	case utils.WebhookPaymentAuthorized:
+		topup.Status = utils.PaymentCapturing
+		return "capturing", tx.Model(topup).Update("status", utils.PaymentCapturing).Error
+	case utils.WebhookPaymentFailed:
+		return "top-up failed", tx.Model(topup).Updates(map[string]interface{}{"status": utils.PaymentFailed, "reason": event.Reason}).Error
+	}
+	return "ignored", nil
+}
+
+// captureTopup captures a top-up marked capturing and credits the wallet. The
+// gateway is called outside any transaction, and since captures can be retried a
+// top-up left capturing by a crash is captured again by the gateway settler.
+func captureTopup(db *gorm.DB, gateway utils.PaymentGateway, topup *models.WalletTopup) (string, error) {
+	captureErr := gateway.Capture(topup.ProviderTx, topup.Amount)
+
+	result := "wallet topped up"
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.WalletTopup
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, topup.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != utils.PaymentCapturing {
+			result = "ignored, top-up is " + current.Status
+			return nil
+		}
+		if captureErr != nil {
+			result = "capture failed"
+			return tx.Model(&current).Updates(map[string]interface{}{"status": utils.PaymentFailed, "reason": "capture failed: " + captureErr.Error()}).Error
+		}
+		if _, err := utils.MoveToWallet(tx, current.UserID, utils.LedgerGateway, current.Amount, models.LedgerTransaction{
+			Type:      utils.LedgerTopup,
+			Reference: fmt.Sprintf("topup-%d", current.ID),
+		}); err != nil {
+			return err
+		}
+		return tx.Model(&current).Update("status", utils.PaymentCaptured).Error
+	})
+	return result, err
+}
+
+// GetWallet — User: their wallet balance and its ledger entries, newest first (?limit=, default 50).
+func GetWallet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		wallet, err := utils.WalletAccount(db, c.GetUint("userId"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open wallet"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		var history []struct {
+			ID           uint    `json:"id"`
+			Type         string  `json:"type"`
+			Reference    string  `json:"reference"`
+			BookingID    *uint   `json:"booking_id"`
+			Amount       float64 `json:"amount"`
+			BalanceAfter float64 `json:"balance_after"`
+			CreatedAt    string  `json:"created_at"`
+		}
+		if err := db.Model(&models.LedgerEntry{}).
+			Select("ledger_transactions.id, ledger_transactions.type, ledger_transactions.reference, ledger_transactions.booking_id, "+
+				"ledger_entries.amount, ledger_entries.balance_after, ledger_entries.created_at").
+			Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+			Where("ledger_entries.account_id = ?", wallet.ID).
+			Order("ledger_entries.id desc").Limit(limit).
+			Scan(&history).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"balance": wallet.Balance, "history": history})
+	}
+}
+
+// TopUpWallet — User: add money to their wallet through the active payment
+// gateway. The wallet is credited when the gateway's webhook confirms the payment.
+func TopUpWallet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Amount float64 `json:"amount"`
+			Method string  `json:"method"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid top-up amount"})
+			return
+		}
+		if req.Method == utils.WalletGateway {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A wallet cannot be topped up from itself"})
+			return
+		}
+
+		topup := models.WalletTopup{
+			UserID: c.GetUint("userId"),
+			Amount: utils.RoundMoney(req.Amount),
+			Method: req.Method,
+			Status: utils.PaymentInitiated,
+		}
+		if err := db.Create(&topup).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start top-up"})
+			return
+		}
+
+		gateway := utils.ActivePaymentGateway()
+		intent, err := gateway.CreateIntent(topup.Amount, req.Method, fmt.Sprintf("topup-%d", topup.ID))
+		if err != nil {
+			db.Model(&topup).Updates(map[string]interface{}{"status": utils.PaymentFailed, "reason": "gateway unavailable"})
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Payment gateway unavailable, please try again"})
+			return
+		}
+		if err := db.Model(&topup).Updates(map[string]interface{}{"gateway": gateway.Name(), "provider_tx": intent.ID}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start top-up"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Top-up initiated",
+			"topup_id":     topup.ID,
+			"amount":       topup.Amount,
+			"gateway":      gateway.Name(),
+			"intent_id":    intent.ID,
+			"checkout_url": intent.CheckoutURL,
+		})
+	}
+}
+
+// RedeemGiftCard — User: move the value of a gift card into their wallet.
+func RedeemGiftCard(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gift card code is required"})
+			return
+		}
+
+		userID := c.GetUint("userId")
+		var card *models.GiftCard
+		var entry *models.LedgerTransaction
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			card, entry, err = utils.RedeemGiftCard(tx, req.Code, userID)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, utils.ErrGiftCardUnusable) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem gift card"})
+			return
+		}
+
+		var credited, balance float64
+		for _, line := range entry.Entries {
+			if line.Amount > 0 {
+				credited, balance = line.Amount, line.BalanceAfter
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Gift card redeemed",
+			"code":     card.Code,
+			"credited": credited,
+			"balance":  balance,
+		})
+	}
+}
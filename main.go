@@ -1,11 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 
 	"cineverse/config"
+	"cineverse/controllers"
 	"cineverse/models"
 	"cineverse/routes"
 	"cineverse/utils"
@@ -22,8 +26,20 @@ func main() {
 		log.Fatalf("migration failed: %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		reconcile(db, os.Args[2:])
+		return
+	}
+
 	utils.SeedDummyTheatres()
 
+	// release seats held by abandoned checkouts
+	utils.StartSeatHoldSweeper(db, time.Minute)
+	// hand released seats to waitlisted users
+	controllers.StartWaitlistDispatcher(db, time.Minute)
+	// finish refunds and captures the gateway has not completed
+	controllers.StartGatewaySettler(db, time.Minute)
+
 	r := routes.SetupRouter()
 	r.Static("/uploads", "./uploads")
 
@@ -36,7 +52,103 @@ func main() {
 	r.Run(addr)
 }
 
+// reconcile runs a payment reconciliation from the command line, for a cron job:
+//
+//	cineverse reconcile [-import settlement.csv -gateway fake] [-fix]
+func reconcile(db *gorm.DB, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	file := flags.String("import", "", "gateway settlement CSV to import first")
+	gateway := flags.String("gateway", utils.ActivePaymentGateway().Name(), "gateway the settlement file is from")
+	fix := flags.Bool("fix", false, "fix the safe mismatches")
+	flags.Parse(args)
+
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("reconcile: %v", err)
+		}
+		imported, err := utils.ImportSettlementCSV(db, *gateway, filepath.Base(*file), f, nil)
+		f.Close()
+		if err != nil {
+			log.Fatalf("reconcile: importing %s: %v", *file, err)
+		}
+		log.Printf("imported %d settlement records from %s", imported.Rows, *file)
+	}
+
+	run, err := utils.RunReconciliation(db, "cli", nil, *fix)
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+	var issues []models.ReconciliationIssue
+	db.Where("run_id = ?", run.ID).Order("id").Find(&issues)
+	for _, issue := range issues {
+		line := fmt.Sprintf("%-26s %s", issue.Kind, issue.Details)
+		if issue.Fixed {
+			line += " [fixed: " + issue.FixAction + "]"
+		}
+		fmt.Println(line)
+	}
+	log.Printf("reconciliation run #%d: %d mismatches, %d fixed", run.ID, run.Mismatches, run.Fixed)
+}
+
 func migrate(db *gorm.DB) error {
-	return db.AutoMigrate(&models.User{}, &models.Admin{}, &models.Movie{}, &models.Show{}, &models.Booking{}, &models.RefreshToken{},
-		&models.Theatre{}, &models.Screen{}, &models.BookingSeat{}, &models.Payment{}, &models.Wishlist{})
+	seedTaxes := !db.Migrator().HasTable(&models.TaxFee{})
+	if err := db.AutoMigrate(&models.User{}, &models.Admin{}, &models.Movie{}, &models.Show{}, &models.Booking{}, &models.RefreshToken{},
+		&models.Theatre{}, &models.Screen{}, &models.BookingSeat{}, &models.Payment{}, &models.Wishlist{},
+		&models.SeatLayout{}, &models.SeatCategory{}, &models.ShowCategoryPrice{}, &models.Refund{},
+		&models.BookingExchange{}, &models.WaitlistEntry{}, &models.IdempotencyKey{}, &models.Invoice{}, &models.CalendarFeed{},
+		&models.PaymentWebhookEvent{}, &models.PaymentTransition{}, &models.SettlementImport{}, &models.SettlementRecord{},
+		&models.ReconciliationRun{}, &models.ReconciliationIssue{}, &models.Promotion{}, &models.BookingDiscount{},
+		&models.PricingRule{}, &models.LoyaltyEntry{}, &models.LedgerAccount{}, &models.LedgerTransaction{}, &models.LedgerEntry{},
+		&models.GiftCard{}, &models.WalletTopup{}, &models.TaxFee{}, &models.BookingCharge{},
+		&models.ConcessionItem{}, &models.FoodOrder{}, &models.FoodOrderItem{}, &models.Cart{}, &models.CartItem{},
+		&models.GroupBooking{}, &models.GroupInvite{}, &models.BookingTransfer{}); err != nil {
+		return err
+	}
+
+	// prices used to include GST at a fixed rate; keep charging it that way until
+	// an admin configures otherwise
+	if seedTaxes {
+		gst := []models.TaxFee{
+			{Name: "GST", Kind: "tax", AppliesTo: "tickets", RateType: "percent", Rate: utils.GSTRate(), Inclusive: true, Active: true},
+			{Name: "GST", Kind: "tax", AppliesTo: "parking", RateType: "percent", Rate: utils.GSTRate(), Inclusive: true, Active: true},
+		}
+		if err := db.Create(&gst).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := utils.MigrateLegacyPaymentStatuses(db); err != nil {
+		return err
+	}
+
+	// refunds recorded before the ledger had types
+	if err := db.Model(&models.Refund{}).Where("type IS NULL OR type = ''").
+		Update("type", gorm.Expr("CASE reason WHEN 'seat_exchange' THEN 'exchange' ELSE 'cancellation' END")).Error; err != nil {
+		return err
+	}
+
+	// seats sold before pricing rules existed were sold at their base price
+	if err := db.Model(&models.BookingSeat{}).Where("base_price = 0 AND pricing_rule_id IS NULL").
+		Update("base_price", gorm.Expr("price")).Error; err != nil {
+		return err
+	}
+
+	// seats of cancelled/expired bookings must not count towards the unique index below
+	if err := utils.ReleaseInactiveSeats(db, 0); err != nil {
+		return err
+	}
+
+	// a booking has one invoice, besides the supplementary invoices of its later food orders
+	if db.Migrator().HasIndex(&models.Invoice{}, "idx_invoices_booking_id") {
+		if err := db.Migrator().DropIndex(&models.Invoice{}, "idx_invoices_booking_id"); err != nil {
+			return err
+		}
+	}
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_booking_main ON invoices (booking_id) WHERE food_order_id IS NULL").Error; err != nil {
+		return err
+	}
+
+	// a seat can belong to only one active booking per show
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_booking_seats_active_seat ON booking_seats (show_id, seat_code) WHERE active").Error
 }
@@ -66,6 +66,18 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
+// StaffMiddleware admits gate staff and admins. Must run after AuthMiddleware.
+func StaffMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString(ContextUserRole)
+		if role != "staff" && role != "admin" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Staff only"})
+			return
+		}
+		c.Next()
+	}
+}
+
 func UserMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"bytes"
+	"cineverse/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyHeader    = "Idempotency-Key"
+	idempotencyKeyTTL    = 24 * time.Hour
+	maxIdempotencyKeyLen = 255
+	// a first request still unanswered after this long died without cleaning up
+	idempotencyAbandonedAfter = 5 * time.Minute
+)
+
+// responseRecorder keeps a copy of everything the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes a handler safe to retry. The first response to a request
+// carrying an Idempotency-Key header is stored per user and replayed for retries
+// with the same key and payload; reusing the key for a different payload is a 422.
+// Server errors and panics are not stored so the client can retry them. Requests
+// without the header pass straight through. Must run after AuthMiddleware.
+func Idempotency(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		userID := c.GetUint(ContextUserID)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		// Keys expire so clients may eventually reuse them, and a key whose first
+		// request never finished is free to retry
+		now := time.Now()
+		db.Where("user_id = ? AND key = ? AND (created_at < ? OR (status_code = 0 AND created_at < ?))",
+			userID, key, now.Add(-idempotencyKeyTTL), now.Add(-idempotencyAbandonedAfter)).
+			Delete(&models.IdempotencyKey{})
+
+		record := models.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash}
+		if err := db.Create(&record).Error; err != nil {
+			if !errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to record idempotency key"})
+				return
+			}
+
+			var existing models.IdempotencyKey
+			if err := db.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load idempotency key"})
+				return
+			}
+
+			switch {
+			case existing.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case existing.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, "application/json; charset=utf-8", []byte(existing.ResponseBody))
+				c.Abort()
+			}
+			return
+		}
+
+		// A panicking handler leaves no response to replay; free the key for a retry
+		defer func() {
+			if r := recover(); r != nil {
+				db.Delete(&record)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			db.Delete(&record)
+			return
+		}
+		db.Model(&record).Updates(map[string]interface{}{
+			"status_code":   status,
+			"response_body": recorder.body.String(),
+		})
+	}
+}
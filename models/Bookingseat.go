@@ -3,10 +3,18 @@ package models
 import "time"
 
 type BookingSeat struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	BookingID uint      `gorm:"not null" json:"booking_id"`
-	ShowID    uint      `gorm:"not null" json:"show_id"`
-	SeatCode  string    `gorm:"size:10;not null" json:"seat_code"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	BookingID     uint       `gorm:"not null" json:"booking_id"`
+	ShowID        uint       `gorm:"not null" json:"show_id"`
+	SeatCode      string     `gorm:"size:10;not null" json:"seat_code"`
+	Price         float64    `json:"price"`
+	BasePrice     float64    `gorm:"type:decimal(10,2);default:0.0" json:"base_price"` // price before the pricing rule
+	PricingRuleID *uint      `json:"pricing_rule_id,omitempty"`
+	PricingRule   string     `gorm:"size:100" json:"pricing_rule,omitempty"` // name of the pricing rule that set the price
+	Category      string     `gorm:"size:50" json:"category,omitempty"`      // seat category name at booking time
+	Active        bool       `gorm:"not null;default:true" json:"active"`    // false once the booking is cancelled or expired; see idx_booking_seats_active_seat
+	AdmittedAt    *time.Time `json:"admitted_at,omitempty"`                  // set when the seat's ticket is scanned at the gate
+	ExchangedAt   *time.Time `json:"exchanged_at,omitempty"`                 // set when a seat exchange gave the seat up; the row stays inactive
+	RefundedAt    *time.Time `json:"refunded_at,omitempty"`                  // set when an admin refunded the seat; the row stays inactive
+	CreatedAt     time.Time  `json:"created_at"`
 }
@@ -10,15 +10,31 @@ type Booking struct {
 	ShowID uint `gorm:"index;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"show_id"`
 	Show   Show `gorm:"foreignKey:ShowID" json:"show"`
 	// StartTime     time.Time     `gorm:"not null" json:"start_time"`
-	SeatsCount    int           `json:"seats_count"`
-	TotalAmount   float64       `gorm:"type:decimal(10,2)" json:"total_amount"`
-	Status        string        `gorm:"type:varchar(20);default:'pending';index" json:"status"` // e.g., "pending", "confirmed", "cancelled"
-	PaymentMethod string        `gorm:"size:50" json:"payment_method"`
-	HasParking    bool          `json:"has_parking" gorm:"default:false"`
-	VehicleType   string        `json:"vehicle_type" gorm:"size:20"` // "Car" or "Bike"
-	ParkingFee    float64       `json:"parking_fee" gorm:"type:decimal(10,2);default:0.0"`
-	CreatedAt     time.Time     `gorm:"autoCreateTime;index" json:"created_at"`
-	UpdatedAt     time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
-	Seats         []BookingSeat `gorm:"foreignKey:BookingID" json:"seats"`
-	Payment       *Payment      `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"payment"`
+	SeatsCount      int               `json:"seats_count"`
+	TotalAmount     float64           `gorm:"type:decimal(10,2)" json:"total_amount"`
+	Status          string            `gorm:"type:varchar(20);default:'pending';index" json:"status"` // e.g., "pending", "confirmed", "cancelled", "expired", or "reserved" for the unclaimed seats of a group booking
+	PaymentMethod   string            `gorm:"size:50" json:"payment_method"`
+	HasParking      bool              `json:"has_parking" gorm:"default:false"`
+	VehicleType     string            `json:"vehicle_type" gorm:"size:20"` // "Car" or "Bike"
+	ParkingFee      float64           `json:"parking_fee" gorm:"type:decimal(10,2);default:0.0"`
+	Discount        float64           `json:"discount" gorm:"type:decimal(10,2);default:0.0"`     // sum of the discount lines, already taken off TotalAmount
+	PointsUsed      int               `json:"points_used" gorm:"default:0"`                       // loyalty points paying part of the seats
+	PointsValue     float64           `json:"points_value" gorm:"type:decimal(10,2);default:0.0"` // what they paid, not included in TotalAmount
+	Fees            float64           `json:"fees" gorm:"type:decimal(10,2);default:0.0"`         // exclusive fee lines, included in TotalAmount
+	Taxes           float64           `json:"taxes" gorm:"type:decimal(10,2);default:0.0"`        // exclusive tax lines, included in TotalAmount
+	FoodAmount      float64           `json:"food_amount" gorm:"type:decimal(10,2);default:0.0"`  // food orders, included in TotalAmount
+	ExpiresAt       *time.Time        `gorm:"index" json:"expires_at"`                            // end of the seat hold while the booking is pending
+	CartID          *uint             `gorm:"index" json:"cart_id,omitempty"`                     // set when booked through a cart checkout
+	GroupID         *uint             `gorm:"index" json:"group_id,omitempty"`                    // set for the seats of a group booking
+	SourceBookingID *uint             `gorm:"index" json:"source_booking_id,omitempty"`           // set when the seats were transferred out of another booking, which paid for them
+	TicketVersion   int               `gorm:"default:0" json:"ticket_version"`                    // bumped when seats are transferred away, voiding earlier tickets
+	CreatedAt       time.Time         `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt       time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+	Seats           []BookingSeat     `gorm:"foreignKey:BookingID" json:"seats"`
+	Payment         *Payment          `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"payment"`
+	Exchanges       []BookingExchange `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"exchanges,omitempty"`
+	Invoice         *Invoice          `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"invoice,omitempty"`
+	Discounts       []BookingDiscount `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"discounts,omitempty"`
+	Charges         []BookingCharge   `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"charges,omitempty"`
+	FoodOrders      []FoodOrder       `gorm:"foreignKey:BookingID" json:"food_orders,omitempty"`
 }
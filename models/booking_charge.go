@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// BookingCharge is a tax or fee line of a booking, as charged when it was booked.
+type BookingCharge struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BookingID uint      `gorm:"index;not null" json:"booking_id"`
+	TaxFeeID  *uint     `gorm:"index;constraint:OnDelete:SET NULL;" json:"tax_fee_id,omitempty"`
+	Kind      string    `gorm:"size:10;not null" json:"kind"` // "tax" or "fee"
+	Name      string    `gorm:"size:100" json:"name"`
+	AppliesTo string    `gorm:"size:10" json:"applies_to"`
+	RateType  string    `gorm:"size:10" json:"rate_type"`
+	Rate      float64   `gorm:"type:decimal(10,2)" json:"rate"`
+	Inclusive bool      `json:"inclusive"`
+	Base      float64   `gorm:"type:decimal(10,2)" json:"base"` // amount a percent rate was applied to
+	Amount    float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// BookingDiscount is a discount line of a booking, taken off its seat subtotal.
+type BookingDiscount struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	BookingID   uint      `gorm:"index;not null" json:"booking_id"`
+	PromotionID *uint     `gorm:"index;constraint:OnDelete:SET NULL;" json:"promotion_id,omitempty"`
+	Code        string    `gorm:"size:40" json:"code"`
+	Description string    `gorm:"size:255" json:"description"`
+	Amount      float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
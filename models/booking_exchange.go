@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// BookingExchange is the audit record of one seat exchange on a booking.
+type BookingExchange struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	BookingID  uint      `gorm:"index;not null" json:"booking_id"`
+	FromShowID uint      `gorm:"not null" json:"from_show_id"`
+	ToShowID   uint      `gorm:"not null" json:"to_show_id"`
+	OldSeats   []string  `gorm:"serializer:json;type:jsonb" json:"old_seats"`
+	NewSeats   []string  `gorm:"serializer:json;type:jsonb" json:"new_seats"`
+	OldAmount  float64   `gorm:"type:decimal(10,2)" json:"old_amount"` // seats with the taxes and fees added to them, before the exchange
+	NewAmount  float64   `gorm:"type:decimal(10,2)" json:"new_amount"` // the same after the exchange
+	Difference float64   `gorm:"type:decimal(10,2)" json:"difference"` // positive: charged, negative: refunded
+	ChargeTx   string    `gorm:"size:200" json:"charge_tx,omitempty"`  // payment reference of an extra charge
+	RefundID   *uint     `json:"refund_id,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
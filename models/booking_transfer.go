@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// BookingTransfer is one user's offer to give seats of a confirmed booking to
+// another registered user. A transfer of the whole booking hands the booking
+// itself over; a transfer of some seats moves them to a new booking of the
+// recipient's. The money stays with the original booking either way.
+type BookingTransfer struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	BookingID    uint       `gorm:"index;not null" json:"booking_id"`
+	ShowID       uint       `gorm:"index;not null" json:"show_id"`
+	Show         Show       `gorm:"foreignKey:ShowID;constraint:OnDelete:CASCADE;" json:"show"`
+	FromUserID   uint       `gorm:"index;not null" json:"from_user_id"`
+	ToUserID     uint       `gorm:"index;not null" json:"to_user_id"`
+	Email        string     `gorm:"size:255" json:"email"` // the recipient's, as the sender entered it
+	SeatCodes    []string   `gorm:"serializer:json;type:jsonb" json:"seat_codes"`
+	WholeBooking bool       `json:"whole_booking"`
+	Status       string     `gorm:"size:20;not null;default:'offered';index" json:"status"` // "offered", "accepted", "declined" or "cancelled"
+	NewBookingID *uint      `gorm:"index" json:"new_booking_id,omitempty"`                  // the recipient's booking once accepted; BookingID itself for a whole booking
+	RespondedAt  *time.Time `json:"responded_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// CalendarFeed is a user's private iCalendar subscription. Only the SHA-256 of
+// the URL token is stored, like refresh tokens.
+type CalendarFeed struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"uniqueIndex;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
+	TokenHash     string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
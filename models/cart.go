@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Cart collects seat selections for several shows so they can be held and paid
+// for together. Checking out holds every show as a pending Booking of the cart;
+// one gateway payment then confirms all of them or none.
+type Cart struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null;constraint:OnDelete:CASCADE;" json:"user_id"`
+	Status     string     `gorm:"size:20;not null;default:'open';index" json:"status"` // "open", "checkout", "paid" or "expired"
+	Total      float64    `gorm:"type:decimal(10,2);default:0.0" json:"total"`         // set at checkout
+	Gateway    string     `gorm:"size:30" json:"gateway,omitempty"`
+	ProviderTx string     `gorm:"size:200;index" json:"provider_tx,omitempty"` // the payment shared by the cart's bookings
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`                        // end of the seat holds once checked out
+	Items      []CartItem `gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE;" json:"items"`
+	Bookings   []Booking  `gorm:"foreignKey:CartID" json:"bookings,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CartItem is the seats wanted for one show of a cart.
+type CartItem struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CartID      uint      `gorm:"index;not null" json:"cart_id"`
+	ShowID      uint      `gorm:"index;not null" json:"show_id"`
+	Show        Show      `gorm:"foreignKey:ShowID;constraint:OnDelete:CASCADE;" json:"show"`
+	SeatCodes   []string  `gorm:"serializer:json;type:jsonb" json:"seat_codes"`
+	HasParking  bool      `gorm:"default:false" json:"has_parking"`
+	VehicleType string    `gorm:"size:20" json:"vehicle_type,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
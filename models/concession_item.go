@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ConcessionItem is something a theatre sells at its counter. A combo bundles
+// other items of the same menu; selling it takes their stock.
+type ConcessionItem struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	TheatreID   uint         `gorm:"index;not null;constraint:OnDelete:CASCADE;" json:"theatre_id"`
+	Name        string       `gorm:"size:100;not null" json:"name"`
+	Description string       `gorm:"size:255" json:"description,omitempty"`
+	Category    string       `gorm:"size:50" json:"category,omitempty"` // e.g. "snacks", "drinks"
+	Price       float64      `gorm:"type:decimal(10,2);not null" json:"price"`
+	Stock       *int         `json:"stock"`                                                  // nil: not tracked
+	Components  []ComboEntry `gorm:"serializer:json;type:jsonb" json:"components,omitempty"` // set for combos
+	Active      bool         `gorm:"not null" json:"active"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// ComboEntry is one item of a combo.
+type ComboEntry struct {
+	ItemID   uint `json:"item_id"`
+	Quantity int  `json:"quantity"`
+}
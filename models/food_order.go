@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// FoodOrder is a concession pre-order attached to a booking, collected at the
+// counter with its pickup code.
+type FoodOrder struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	BookingID   uint            `gorm:"index;not null;constraint:OnDelete:CASCADE;" json:"booking_id"`
+	ShowID      uint            `gorm:"index;not null" json:"show_id"`
+	PickupCode  string          `gorm:"size:20;uniqueIndex;not null" json:"pickup_code"`
+	Status      string          `gorm:"size:20;not null;default:'pending';index" json:"status"` // "pending", "confirmed", "collected" or "cancelled"
+	Total       float64         `gorm:"type:decimal(10,2);not null" json:"total"`
+	ChargeTx    string          `gorm:"size:200" json:"charge_tx,omitempty"` // payment reference when charged after the booking was paid
+	CollectedAt *time.Time      `json:"collected_at,omitempty"`
+	CollectedBy *uint           `json:"collected_by,omitempty"`
+	Items       []FoodOrderItem `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"items"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// FoodOrderItem is one line of a food order, at the price it was sold for.
+type FoodOrderItem struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	OrderID   uint         `gorm:"index;not null" json:"order_id"`
+	ItemID    *uint        `gorm:"index;constraint:OnDelete:SET NULL;" json:"item_id,omitempty"`
+	Name      string       `gorm:"size:100" json:"name"`
+	UnitPrice float64      `gorm:"type:decimal(10,2);not null" json:"unit_price"`
+	Quantity  int          `gorm:"not null" json:"quantity"`
+	Amount    float64      `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Taken     []ComboEntry `gorm:"serializer:json;type:jsonb" json:"-"` // stock taken, given back if the order is cancelled
+}
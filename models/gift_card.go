@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// GiftCard is a code issued by an admin whose value a customer redeems into their
+// wallet. The remaining value is the balance of the card's ledger account.
+type GiftCard struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	Code       string         `gorm:"size:30;uniqueIndex;not null" json:"code"`
+	Amount     float64        `gorm:"type:decimal(10,2);not null" json:"amount"` // value at issue
+	Note       string         `gorm:"size:255" json:"note,omitempty"`
+	AdminID    *uint          `gorm:"index" json:"admin_id,omitempty"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	RedeemedBy *uint          `gorm:"index" json:"redeemed_by,omitempty"`
+	RedeemedAt *time.Time     `json:"redeemed_at,omitempty"`
+	Account    *LedgerAccount `gorm:"foreignKey:GiftCardID" json:"account,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
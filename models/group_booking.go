@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// GroupBooking is a block of seats one user reserves for a group. The organiser
+// invites other users to claim seats of it; each claimant gets a Booking of their
+// own to pay for, and the seats nobody paid for are released at the deadline.
+// Seats not claimed yet are held by the organiser's "reserved" HoldBooking.
+type GroupBooking struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	OrganiserID   uint          `gorm:"index;not null" json:"organiser_id"`
+	ShowID        uint          `gorm:"index;not null" json:"show_id"`
+	Show          Show          `gorm:"foreignKey:ShowID;constraint:OnDelete:CASCADE;" json:"show"`
+	HoldBookingID uint          `gorm:"uniqueIndex;not null" json:"hold_booking_id"`
+	Deadline      time.Time     `gorm:"not null" json:"deadline"`
+	Status        string        `gorm:"size:20;not null;default:'open';index" json:"status"` // "open", "closed" or "cancelled"
+	Invites       []GroupInvite `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE;" json:"invites,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// GroupInvite offers some seats of a group booking to one user.
+type GroupInvite struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	GroupID   uint          `gorm:"index;not null" json:"group_id"`
+	Group     *GroupBooking `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+	UserID    uint          `gorm:"index;not null" json:"user_id"`
+	Email     string        `gorm:"size:255" json:"email"` // the invitee's, as the organiser entered it
+	SeatCodes []string      `gorm:"serializer:json;type:jsonb" json:"seat_codes"`
+	Status    string        `gorm:"size:20;not null;default:'invited';index" json:"status"` // "invited", "claimed", "declined", "revoked" or "lapsed"
+	BookingID *uint         `json:"booking_id,omitempty"`                                   // the claimant's booking, once claimed
+	Booking   *Booking      `gorm:"foreignKey:BookingID" json:"booking,omitempty"`
+	ClaimedAt *time.Time    `json:"claimed_at,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the first response to a request sent with an
+// Idempotency-Key header so that retries of it can be replayed.
+type IdempotencyKey struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"uniqueIndex:idx_idempotency_user_key;not null" json:"user_id"`
+	Key          string    `gorm:"uniqueIndex:idx_idempotency_user_key;size:255;not null" json:"key"`
+	RequestHash  string    `gorm:"size:64;not null" json:"request_hash"` // sha256 of method, path and body
+	StatusCode   int       `json:"status_code"`                          // 0 while the first request is still running
+	ResponseBody string    `gorm:"type:text" json:"response_body"`
+	CreatedAt    time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
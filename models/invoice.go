@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Invoice is the tax invoice of a paid booking. The taxes of the booking, whether
+// included in its prices or added to them, are split between CGST and SGST. Food
+// ordered after the booking was paid gets a supplementary invoice of its own,
+// numbered in the same sequence.
+type Invoice struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	BookingID    uint      `gorm:"index:idx_invoices_booking;not null" json:"booking_id"` // one invoice per booking without a food order, see migrate
+	FoodOrderID  *uint     `gorm:"uniqueIndex" json:"food_order_id,omitempty"`            // set on supplementary invoices
+	Sequence     uint      `gorm:"uniqueIndex;not null" json:"sequence"`                  // gap-free running number
+	Number       string    `gorm:"size:30;uniqueIndex;not null" json:"number"`
+	SeatSubtotal float64   `gorm:"type:decimal(10,2)" json:"seat_subtotal"`
+	Discount     float64   `gorm:"type:decimal(10,2);default:0.0" json:"discount"`
+	Fees         float64   `gorm:"type:decimal(10,2);default:0.0" json:"fees"` // fees added to the seat prices
+	ParkingFee   float64   `gorm:"type:decimal(10,2)" json:"parking_fee"`
+	Food         float64   `gorm:"type:decimal(10,2);default:0.0" json:"food"`        // food orders placed before payment, or the food of a supplementary invoice
+	PointsPaid   float64   `gorm:"type:decimal(10,2);default:0.0" json:"points_paid"` // part of Total paid with loyalty points
+	TaxRate      float64   `gorm:"type:decimal(5,2)" json:"tax_rate"`                 // percent of the taxable value
+	TaxableValue float64   `gorm:"type:decimal(10,2)" json:"taxable_value"`
+	CGST         float64   `gorm:"column:cgst;type:decimal(10,2)" json:"cgst"`
+	SGST         float64   `gorm:"column:sgst;type:decimal(10,2)" json:"sgst"`
+	Total        float64   `gorm:"type:decimal(10,2)" json:"total"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// LedgerAccount holds stored value: a customer's wallet, a gift card, or one of the
+// system accounts money enters and leaves the ledger through. Balance is kept in
+// step with the account's entries; only system accounts may go negative.
+type LedgerAccount struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Code       string    `gorm:"size:60;uniqueIndex;not null" json:"code"` // "wallet:<user>", "gift_card:<card>", "system:<name>"
+	Kind       string    `gorm:"size:20;not null;index" json:"kind"`       // "wallet", "gift_card" or "system"
+	UserID     *uint     `gorm:"uniqueIndex" json:"user_id,omitempty"`     // owner of a wallet
+	GiftCardID *uint     `gorm:"uniqueIndex" json:"gift_card_id,omitempty"`
+	Balance    float64   `gorm:"type:decimal(12,2);not null;default:0" json:"balance"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// LedgerTransaction is one movement of value. Its entries always sum to zero:
+// whatever one account gains, others lose.
+type LedgerTransaction struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	Type      string        `gorm:"size:30;not null;index" json:"type"` // "topup", "payment", "refund", "charge", "gift_card_issue", "gift_card_redeem"
+	Reference string        `gorm:"size:100;index" json:"reference,omitempty"`
+	BookingID *uint         `gorm:"index" json:"booking_id,omitempty"`
+	Note      string        `gorm:"size:255" json:"note,omitempty"`
+	Entries   []LedgerEntry `gorm:"foreignKey:TransactionID" json:"entries,omitempty"`
+	CreatedAt time.Time     `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// LedgerEntry is one leg of a transaction: positive credits the account, negative debits it.
+type LedgerEntry struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"index;not null" json:"transaction_id"`
+	AccountID     uint      `gorm:"index;not null" json:"account_id"`
+	Amount        float64   `gorm:"type:decimal(12,2);not null" json:"amount"`
+	BalanceAfter  float64   `gorm:"type:decimal(12,2)" json:"balance_after"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
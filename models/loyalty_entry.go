@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LoyaltyEntry is one line of a user's loyalty points ledger; the balance is the
+// sum of Points. Entries that add points are lots which expire: Remaining is what
+// is left of a lot after redemptions, reversals and expiry took from it, oldest
+// expiry first.
+type LoyaltyEntry struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	BookingID *uint      `gorm:"index" json:"booking_id,omitempty"`
+	Type      string     `gorm:"size:20;not null;index" json:"type"` // "earn", "redeem", "restore", "reverse", "expire"
+	Points    int        `gorm:"not null" json:"points"`             // positive for lots, negative for deductions
+	Remaining int        `gorm:"default:0" json:"remaining,omitempty"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	Note      string     `gorm:"size:255" json:"note,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
@@ -6,9 +6,14 @@ type Payment struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
 	BookingID  uint      `gorm:"index;not null;unique" json:"booking_id"`
 	Method     string    `gorm:"size:50" json:"method"`
-	ProviderTx string    `gorm:"size:200" json:"provider_tx,omitempty"` // e.g., payment gateway reference
+	Gateway    string    `gorm:"size:30" json:"gateway"`
+	ProviderTx string    `gorm:"size:200;index" json:"provider_tx,omitempty"` // e.g., payment gateway reference
 	Amount     float64   `gorm:"type:decimal(10,2)" json:"amount"`
-	Status     string    `gorm:"size:50;default:'completed'" json:"status"`
+	Status     string    `gorm:"size:50;default:'initiated'" json:"status"` // see utils.TransitionPayment for the lifecycle
+	Attempts   int       `gorm:"not null;default:1" json:"attempts"`
 	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	Refunds    []Refund  `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"refunds,omitempty"`
+
+	Transitions []PaymentTransition `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"transitions,omitempty"`
 }
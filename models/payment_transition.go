@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PaymentTransition is one step in a payment's lifecycle, kept as its history.
+type PaymentTransition struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	PaymentID  uint      `gorm:"index;not null" json:"payment_id"`
+	FromStatus string    `gorm:"size:50" json:"from_status"`
+	ToStatus   string    `gorm:"size:50;not null" json:"to_status"`
+	Reason     string    `gorm:"size:200" json:"reason,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PaymentWebhookEvent records each gateway webhook once it is handled, so a
+// redelivered event is acknowledged without being applied twice.
+type PaymentWebhookEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Gateway    string    `gorm:"size:30;not null;uniqueIndex:idx_webhook_gateway_event" json:"gateway"`
+	EventID    string    `gorm:"size:100;not null;uniqueIndex:idx_webhook_gateway_event" json:"event_id"`
+	Type       string    `gorm:"size:50" json:"type"`
+	IntentID   string    `gorm:"size:200;index" json:"intent_id"`
+	Result     string    `gorm:"size:200" json:"result"`
+	ReceivedAt time.Time `gorm:"autoCreateTime" json:"received_at"`
+}
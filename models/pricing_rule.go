@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// PricingRule adjusts seat prices of the shows it matches. Every condition that is
+// set must hold: unset conditions and scopes match everything. Of the matching
+// rules only the one with the highest priority applies.
+type PricingRule struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"size:100;not null" json:"name"` // e.g. "Weekend evening surcharge"
+	Priority  int    `gorm:"default:0;index" json:"priority"`
+	Active    bool   `gorm:"not null" json:"active"`
+	MovieID   *uint  `gorm:"index" json:"movie_id,omitempty"`
+	TheatreID *uint  `gorm:"index" json:"theatre_id,omitempty"`
+	ShowID    *uint  `gorm:"index" json:"show_id,omitempty"`
+
+	// Conditions on the show
+	Days     []int  `gorm:"serializer:json;type:jsonb" json:"days,omitempty"` // weekdays of the show, 0 = Sunday
+	FromTime string `gorm:"size:5" json:"from_time,omitempty"`                // show starts at or after, "HH:MM"
+	ToTime   string `gorm:"size:5" json:"to_time,omitempty"`                  // show starts before, "HH:MM"
+	// Conditions at the time of booking
+	MinHoursBefore int     `gorm:"default:0" json:"min_hours_before"`                  // early bird: booked at least this long before the show
+	MinOccupancy   float64 `gorm:"type:decimal(5,2);default:0.0" json:"min_occupancy"` // percent of seats booked
+
+	AdjustmentType string  `gorm:"size:10;not null" json:"adjustment_type"`  // "percent" or "flat"
+	Value          float64 `gorm:"type:decimal(10,2);not null" json:"value"` // positive surcharge, negative discount
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Promotion is a promo code of a discount campaign. Scoping fields left empty
+// apply to every movie, theatre or show; limits of 0 mean unlimited.
+type Promotion struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Code         string     `gorm:"size:40;uniqueIndex;not null" json:"code"` // stored upper case
+	Description  string     `gorm:"size:255" json:"description"`
+	DiscountType string     `gorm:"size:10;not null" json:"discount_type"` // "percent" or "flat"
+	Value        float64    `gorm:"type:decimal(10,2);not null" json:"value"`
+	MaxDiscount  float64    `gorm:"type:decimal(10,2);default:0.0" json:"max_discount"` // cap on a percent discount
+	MinSeats     int        `gorm:"default:0" json:"min_seats"`
+	MovieID      *uint      `gorm:"index" json:"movie_id,omitempty"`
+	TheatreID    *uint      `gorm:"index" json:"theatre_id,omitempty"`
+	ShowID       *uint      `gorm:"index" json:"show_id,omitempty"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	UsageLimit   int        `gorm:"default:0" json:"usage_limit"`    // redemptions across all users
+	PerUserLimit int        `gorm:"default:0" json:"per_user_limit"` // redemptions per user
+	Active       bool       `gorm:"not null" json:"active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
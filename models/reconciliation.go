@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ReconciliationRun is one comparison of bookings, payments and settlement records.
+type ReconciliationRun struct {
+	ID         uint                  `gorm:"primaryKey" json:"id"`
+	Trigger    string                `gorm:"size:20" json:"trigger"` // "admin" or "cli"
+	AdminID    *uint                 `json:"admin_id,omitempty"`
+	AutoFix    bool                  `json:"auto_fix"`
+	Mismatches int                   `json:"mismatches"`
+	Fixed      int                   `json:"fixed"`
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt *time.Time            `json:"finished_at"`
+	Issues     []ReconciliationIssue `gorm:"foreignKey:RunID;constraint:OnDelete:CASCADE;" json:"issues,omitempty"`
+}
+
+// ReconciliationIssue is a mismatch found by a run and, for the safe cases, the
+// fix that was applied: the audit trail of automatic corrections.
+type ReconciliationIssue struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	RunID         uint   `gorm:"index;not null" json:"run_id"`
+	Kind          string `gorm:"size:50;index" json:"kind"`
+	BookingID     *uint  `gorm:"index" json:"booking_id,omitempty"`
+	PaymentID     *uint  `gorm:"index" json:"payment_id,omitempty"`
+	TransactionID string `gorm:"size:200" json:"transaction_id,omitempty"`
+	Details       string `gorm:"type:text" json:"details"`
+	Fixed         bool   `json:"fixed"`
+	FixAction     string `gorm:"size:200" json:"fix_action,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Refund is one entry of the refund ledger of a payment. The amounts of all
+// refunds of a payment never exceed what was captured.
+type Refund struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	PaymentID     uint      `gorm:"index;not null" json:"payment_id"`
+	BookingID     uint      `gorm:"index;not null" json:"booking_id"`
+	Type          string    `gorm:"size:30;index" json:"type"` // "cancellation", "exchange", "full", "partial", "seats", "parking"
+	Amount        float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	SeatAmount    float64   `gorm:"type:decimal(10,2);default:0.0" json:"seat_amount"`
+	ParkingAmount float64   `gorm:"type:decimal(10,2);default:0.0" json:"parking_amount"`
+	FoodAmount    float64   `gorm:"type:decimal(10,2);default:0.0" json:"food_amount"`
+	SeatCodes     []string  `gorm:"serializer:json;type:jsonb" json:"seat_codes,omitempty"` // seats given up by a seat-level refund
+	Reason        string    `gorm:"size:100" json:"reason"`                                 // e.g. "customer_cancellation"
+	Note          string    `gorm:"type:text" json:"note,omitempty"`
+	AdminID       *uint     `gorm:"index" json:"admin_id,omitempty"` // set when an admin issued the refund
+	Status        string    `gorm:"size:50;default:'processed'" json:"status"`
+	Destination   string    `gorm:"size:20;default:'source'" json:"destination"` // "wallet" or "source", the original means of payment
+	ProviderRef   string    `gorm:"size:200" json:"provider_ref,omitempty"`      // gateway refund reference, or ledger transaction for wallet refunds
+	CreatedAt     time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
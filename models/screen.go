@@ -3,11 +3,12 @@ package models
 import "time"
 
 type Screen struct {
-	ID         uint    `gorm:"primaryKey"`
-	Name       string  `gorm:"not null" json:"name"`
-	SeatsTotal int     `json:"seats_total"`
-	TheatreID  uint    `gorm:"index;not null" json:"theatre_id"`
-	Theatre    Theatre `gorm:"foreignKey:TheatreID" json:"theatre"`
+	ID         uint        `gorm:"primaryKey"`
+	Name       string      `gorm:"not null" json:"name"`
+	SeatsTotal int         `json:"seats_total"`
+	TheatreID  uint        `gorm:"index;not null" json:"theatre_id"`
+	Theatre    Theatre     `gorm:"foreignKey:TheatreID" json:"theatre"`
+	Layout     *SeatLayout `gorm:"foreignKey:ScreenID;constraint:OnDelete:CASCADE;" json:"layout,omitempty"`
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
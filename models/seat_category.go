@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SeatCategory groups rows and individual seats of a screen under one price tier,
+// e.g. "Recliner" or "Premium". Seats outside every category are sold at Show.Price.
+type SeatCategory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ScreenID  uint      `gorm:"index;not null" json:"screen_id"`
+	Name      string    `gorm:"size:50;not null" json:"name"`
+	Price     float64   `gorm:"type:decimal(10,2);default:0.0" json:"price"` // default price; 0 falls back to Show.Price
+	Rows      []string  `gorm:"serializer:json;type:jsonb" json:"rows"`      // row labels in this category
+	Seats     []string  `gorm:"serializer:json;type:jsonb" json:"seats"`     // single seats, taking precedence over rows
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShowCategoryPrice overrides the price of a seat category for one show.
+type ShowCategoryPrice struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	ShowID         uint         `gorm:"uniqueIndex:idx_show_category_price;not null" json:"show_id"`
+	SeatCategoryID uint         `gorm:"uniqueIndex:idx_show_category_price;not null" json:"seat_category_id"`
+	SeatCategory   SeatCategory `gorm:"foreignKey:SeatCategoryID;constraint:OnDelete:CASCADE;" json:"seat_category"`
+	Price          float64      `gorm:"type:decimal(10,2);not null" json:"price"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
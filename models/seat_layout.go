@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// SeatLayout describes the physical seating of a screen, row by row.
+type SeatLayout struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ScreenID  uint      `gorm:"uniqueIndex;not null" json:"screen_id"`
+	Rows      []SeatRow `gorm:"serializer:json;type:jsonb;not null" json:"rows"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SeatRow is one row of a SeatLayout. Positions are numbered 1..Seats from the
+// left and a seat is named by its row label and position, e.g. "A7".
+type SeatRow struct {
+	Label    string `json:"label"`              // e.g. "A"
+	Seats    int    `json:"seats"`              // number of positions in the row
+	Aisles   []int  `json:"aisles,omitempty"`   // positions followed by an aisle
+	Gaps     []int  `json:"gaps,omitempty"`     // positions with no seat at all
+	Disabled []int  `json:"disabled,omitempty"` // seats that exist but are not sold
+}
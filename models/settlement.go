@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SettlementImport is one gateway settlement file loaded for reconciliation.
+type SettlementImport struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Gateway   string    `gorm:"size:30;not null" json:"gateway"`
+	Filename  string    `gorm:"size:255" json:"filename"`
+	Rows      int       `json:"rows"`
+	AdminID   *uint     `json:"admin_id,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SettlementRecord is one transaction as the gateway reports it. Importing a
+// newer file updates records already seen.
+type SettlementRecord struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ImportID      uint      `gorm:"index;not null" json:"import_id"`
+	Gateway       string    `gorm:"size:30;not null;uniqueIndex:idx_settlement_tx" json:"gateway"`
+	TransactionID string    `gorm:"size:200;not null;uniqueIndex:idx_settlement_tx" json:"transaction_id"` // payment intent or refund reference
+	Type          string    `gorm:"size:20;not null;uniqueIndex:idx_settlement_tx" json:"type"`            // "payment" or "refund"
+	Status        string    `gorm:"size:20;not null" json:"status"`                                        // "captured", "failed" or "refunded"
+	Amount        float64   `gorm:"type:decimal(10,2)" json:"amount"`
+	Reference     string    `gorm:"size:200" json:"reference,omitempty"`
+	SettledAt     time.Time `gorm:"index" json:"settled_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// TaxFee is a tax or fee charged on bookings. A rule without a theatre applies
+// everywhere; a theatre's own rule of the same kind and name replaces it there.
+// Inclusive rules are already part of the price and are only itemised; the others
+// are added on top of it.
+type TaxFee struct {
+	ID        uint    `gorm:"primaryKey" json:"id"`
+	Name      string  `gorm:"size:100;not null" json:"name"` // e.g. "GST", "Convenience fee"
+	Kind      string  `gorm:"size:10;not null" json:"kind"`  // "tax" or "fee"
+	TheatreID *uint   `gorm:"index;constraint:OnDelete:CASCADE;" json:"theatre_id,omitempty"`
+	AppliesTo string  `gorm:"size:10;not null;default:'tickets'" json:"applies_to"` // "tickets" (with their fees) or "parking"
+	RateType  string  `gorm:"size:10;not null" json:"rate_type"`                    // "percent" or "per_seat"
+	Rate      float64 `gorm:"type:decimal(10,2);not null" json:"rate"`
+	Inclusive bool    `gorm:"not null" json:"inclusive"`
+	Active    bool    `gorm:"not null" json:"active"`
+	Position  int     `gorm:"default:0" json:"position"` // order on the bill
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -19,5 +19,6 @@ type User struct {
 	Bookings      []Booking      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	BookingsCount int64          `gorm:"-" json:"bookings_count,omitempty"`
 	Wishlist      []Wishlist     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Wallet        *LedgerAccount `gorm:"foreignKey:UserID" json:"wallet,omitempty"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 }
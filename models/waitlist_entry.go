@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// WaitlistEntry queues a user for seats of a sold-out show. When seats free up the
+// entry is offered a pending Booking holding them for a short time.
+type WaitlistEntry struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ShowID      uint       `gorm:"index;not null" json:"show_id"`
+	Show        Show       `gorm:"foreignKey:ShowID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"show"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	SeatsWanted int        `gorm:"not null" json:"seats_wanted"`
+	Status      string     `gorm:"size:20;default:'waiting';index" json:"status"` // "waiting", "offered", "fulfilled", "lapsed", "cancelled"
+	BookingID   *uint      `json:"booking_id,omitempty"`                          // the offered booking, once seats are held for this entry
+	OfferedAt   *time.Time `json:"offered_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// WalletTopup is money a customer adds to their wallet through a payment gateway.
+// The wallet is credited when the gateway's webhook reports the payment authorized.
+type WalletTopup struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"user_id"`
+	Amount     float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Method     string    `gorm:"size:50" json:"method"`
+	Gateway    string    `gorm:"size:30" json:"gateway"`
+	ProviderTx string    `gorm:"size:200;index" json:"provider_tx"`
+	Status     string    `gorm:"size:20;not null;default:'initiated'" json:"status"` // "initiated", "capturing", "captured" or "failed"
+	Reason     string    `gorm:"size:255" json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -34,6 +34,13 @@ func SetupRouter() *gin.Engine {
 		api.GET("/movies", controllers.GetMovies(config.DB))
 		api.GET("/movies/:id", controllers.GetMovieDetails(config.DB))
 		api.GET("/movies/:id/shows", controllers.GetShowsByMovie(config.DB))
+		api.GET("/theatres/:id/concessions", controllers.GetTheatreConcessions(config.DB))
+
+		// Payment gateway webhooks, authenticated by the gateway's signature
+		api.POST("/payments/webhook/:gateway", controllers.PaymentWebhook(config.DB))
+
+		// Private calendar feeds, authenticated by the token in the URL
+		api.GET("/calendar/:token", controllers.CalendarFeed(config.DB))
 	}
 
 	// Protected User Routes (Require Login)
@@ -44,18 +51,66 @@ func SetupRouter() *gin.Engine {
 		user.GET("/movies/:id", controllers.GetMovieWithShows(config.DB))
 		user.GET("/movies/shows/upcoming", controllers.GetUpcomingShows(config.DB))
 
-		user.POST("/bookings", controllers.CreateBooking(config.DB))
+		user.POST("/bookings", middlewares.Idempotency(config.DB), controllers.CreateBooking(config.DB))
 		user.GET("/bookings/:id", controllers.GetBookingDetailsUser(config.DB))
 		user.GET("/bookings/user", controllers.GetUserBookings(config.DB))
+		user.GET("/bookings.ics", controllers.GetUserBookingsCalendar(config.DB))
+		user.GET("/bookings/:id/calendar.ics", controllers.GetBookingCalendar(config.DB))
+		user.GET("/bookings/:id/cancellation", controllers.GetCancellationQuote(config.DB))
+		user.POST("/bookings/:id/cancel", controllers.CancelBooking(config.DB))
+		user.POST("/bookings/:id/exchange", controllers.ExchangeBookingSeats(config.DB))
+		user.GET("/bookings/:id/exchanges", controllers.GetBookingExchanges(config.DB))
+		user.POST("/bookings/:id/food", controllers.AddBookingFood(config.DB))
+		user.POST("/bookings/:id/transfer", controllers.CreateBookingTransfer(config.DB))
+		user.GET("/bookings/:id/ticket", controllers.GetBookingTicket(config.DB))
+		user.GET("/bookings/:id/ticket/qr", controllers.GetBookingTicketQR(config.DB))
+		user.GET("/bookings/:id/ticket.pdf", controllers.GetBookingTicketPDF(config.DB))
+		user.GET("/bookings/:id/invoice.pdf", controllers.GetBookingInvoicePDF(config.DB))
 		user.GET("/shows/:id/seats", controllers.GetShowSeats(config.DB))
+		user.GET("/shows/:id/seats/stream", controllers.StreamShowSeats(config.DB))
+		user.POST("/shows/:id/waitlist", controllers.JoinWaitlist(config.DB))
+
+		user.POST("/calendar/feed", controllers.CreateCalendarFeed(config.DB))
+		user.DELETE("/calendar/feed", controllers.DeleteCalendarFeed(config.DB))
+
+		user.GET("/waitlist", controllers.GetUserWaitlist(config.DB))
+		user.DELETE("/waitlist/:id", controllers.LeaveWaitlist(config.DB))
+
+		user.GET("/loyalty", controllers.GetLoyaltySummary(config.DB))
+
+		user.GET("/cart", controllers.GetCart(config.DB))
+		user.POST("/cart/items", controllers.AddCartItem(config.DB))
+		user.DELETE("/cart/items/:id", controllers.RemoveCartItem(config.DB))
+		user.POST("/cart/checkout", middlewares.Idempotency(config.DB), controllers.CheckoutCart(config.DB))
+		user.GET("/carts/:id", controllers.GetUserCart(config.DB))
+		user.POST("/carts/:id/retry", controllers.RetryCartPayment(config.DB))
+
+		user.POST("/groups", controllers.CreateGroupBooking(config.DB))
+		user.GET("/groups/:id", controllers.GetGroupBooking(config.DB))
+		user.DELETE("/groups/:id", controllers.CancelGroupBooking(config.DB))
+		user.POST("/groups/:id/invites", controllers.InviteToGroup(config.DB))
+		user.DELETE("/groups/:id/invites/:inviteId", controllers.RevokeGroupInvite(config.DB))
+		user.GET("/group-invites", controllers.GetUserGroupInvites(config.DB))
+		user.POST("/group-invites/:id/claim", middlewares.Idempotency(config.DB), controllers.ClaimGroupInvite(config.DB))
+		user.POST("/group-invites/:id/decline", controllers.DeclineGroupInvite(config.DB))
+
+		user.GET("/transfers", controllers.GetUserTransfers(config.DB))
+		user.POST("/transfers/:id/accept", controllers.AcceptBookingTransfer(config.DB))
+		user.POST("/transfers/:id/decline", controllers.DeclineBookingTransfer(config.DB))
+		user.DELETE("/transfers/:id", controllers.CancelBookingTransfer(config.DB))
+
+		user.GET("/wallet", controllers.GetWallet(config.DB))
+		user.POST("/wallet/topup", controllers.TopUpWallet(config.DB))
+		user.POST("/wallet/redeem", controllers.RedeemGiftCard(config.DB))
 
 		user.GET("/wishlist", controllers.GetWishlist(config.DB))
 		user.POST("/wishlist", controllers.AddToWishlist(config.DB))
 		user.DELETE("/wishlist/:id", controllers.RemoveFromWishlist(config.DB))
 
-		user.POST("/payments/initiate", controllers.InitiatePayment(config.DB))
-		user.POST("/payments/mock/confirm/:id", controllers.MockConfirmPayment(config.DB))
+		user.POST("/payments/initiate", middlewares.Idempotency(config.DB), controllers.InitiatePayment(config.DB))
 		user.GET("/payments/user", controllers.GetUserPayments(config.DB))
+		user.GET("/payments/:id", controllers.GetPaymentDetails(config.DB))
+		user.POST("/payments/:id/retry", controllers.RetryPayment(config.DB))
 
 	}
 
@@ -82,16 +137,57 @@ func SetupRouter() *gin.Engine {
 
 		admin.GET("/theatres", controllers.GetAllTheatres(db))
 		admin.GET("/theatres/:id/screens", controllers.GetScreensByTheatre(db))
+		admin.GET("/screens/:id/layout", controllers.GetScreenLayout(db))
+		admin.POST("/screens/:id/layout", controllers.CreateScreenLayout(db))
+		admin.PUT("/screens/:id/layout", controllers.UpdateScreenLayout(db))
+		admin.GET("/screens/:id/categories", controllers.GetScreenCategories(db))
+		admin.POST("/screens/:id/categories", controllers.CreateSeatCategory(db))
+		admin.PUT("/categories/:id", controllers.UpdateSeatCategory(db))
+		admin.DELETE("/categories/:id", controllers.DeleteSeatCategory(db))
 
 		admin.GET("/shows", controllers.AdminListShows(db))
 		admin.POST("/shows", controllers.AdminAddShow(db))
 		admin.PUT("/shows/:id", controllers.AdminEditShow(db))
 		admin.DELETE("/shows/:id", controllers.AdminDeleteShow(db))
 		admin.GET("/shows/:id/seats", controllers.GetShowSeats(db))
+		admin.GET("/shows/:id/seats/stream", controllers.StreamShowSeats(db))
+		admin.GET("/shows/:id/prices", controllers.GetShowCategoryPrices(db))
+		admin.PUT("/shows/:id/prices", controllers.SetShowCategoryPrices(db))
+		admin.GET("/shows/:id/pricing", controllers.AdminGetShowPricing(db))
+		admin.GET("/pricing-rules", controllers.AdminListPricingRules(db))
+		admin.POST("/pricing-rules", controllers.AdminCreatePricingRule(db))
+		admin.PUT("/pricing-rules/:id", controllers.AdminUpdatePricingRule(db))
+		admin.DELETE("/pricing-rules/:id", controllers.AdminDeletePricingRule(db))
+		admin.GET("/tax-fees", controllers.AdminListTaxFees(db))
+		admin.POST("/tax-fees", controllers.AdminCreateTaxFee(db))
+		admin.PUT("/tax-fees/:id", controllers.AdminUpdateTaxFee(db))
+		admin.DELETE("/tax-fees/:id", controllers.AdminDeleteTaxFee(db))
+
+		admin.GET("/theatres/:id/concessions", controllers.AdminListConcessions(db))
+		admin.POST("/theatres/:id/concessions", controllers.AdminCreateConcession(db))
+		admin.PUT("/concessions/:id", controllers.AdminUpdateConcession(db))
+		admin.DELETE("/concessions/:id", controllers.AdminDeleteConcession(db))
 
 		admin.GET("/bookings", controllers.GetAllBookings(db))
 		admin.GET("/bookings/:id", controllers.GetBookingDetails(db))
 		admin.PUT("/bookings/:id/status", controllers.UpdateBookingStatus(db))
+		admin.GET("/bookings/:id/ticket.pdf", controllers.AdminGetBookingTicketPDF(db))
+		admin.GET("/bookings/:id/refunds", controllers.AdminGetBookingRefunds(db))
+		admin.POST("/bookings/:id/refunds", controllers.AdminRefundBooking(db))
+		admin.GET("/refunds", controllers.AdminListRefunds(db))
+		admin.GET("/promotions", controllers.AdminListPromotions(db))
+		admin.POST("/promotions", controllers.AdminCreatePromotion(db))
+		admin.PUT("/promotions/:id", controllers.AdminUpdatePromotion(db))
+		admin.DELETE("/promotions/:id", controllers.AdminDeletePromotion(db))
+		admin.GET("/promotions/:id/stats", controllers.AdminGetPromotionStats(db))
+		admin.POST("/reconciliation/settlements", controllers.AdminImportSettlement(db))
+		admin.POST("/reconciliation/runs", controllers.AdminRunReconciliation(db))
+		admin.GET("/reconciliation/runs", controllers.AdminListReconciliationRuns(db))
+		admin.GET("/reconciliation/runs/:id", controllers.AdminGetReconciliationRun(db))
+		admin.GET("/gift-cards", controllers.AdminListGiftCards(db))
+		admin.POST("/gift-cards", controllers.AdminIssueGiftCards(db))
+		admin.GET("/ledger/audit", controllers.AdminAuditLedger(db))
+		admin.GET("/bookings/:id/invoice.pdf", controllers.AdminGetBookingInvoicePDF(db))
 		admin.DELETE("/bookings/:id", controllers.DeleteBooking(db))
 
 		admin.POST("users", controllers.AddUser(db))
@@ -99,6 +195,19 @@ func SetupRouter() *gin.Engine {
 		admin.GET("/users/:id", controllers.GetUserDetails(db))
 		admin.PUT("/users/:id/block", controllers.BlockUser(db))
 		admin.DELETE("/users/:id", controllers.DeleteUser(db))
+
+		admin.GET("/staff", controllers.AdminListStaff(db))
+		admin.POST("/staff", controllers.AdminCreateStaff(db))
+		admin.PUT("/staff/:id/role", controllers.AdminSetAccountRole(db))
+	}
+
+	// Staff Routes (Gate Check-in)
+
+	staff := r.Group("/api/staff").Use(middlewares.AuthMiddleware(), middlewares.StaffMiddleware())
+	{
+		staff.POST("/checkin", controllers.CheckInTicket(config.DB))
+		staff.GET("/shows/:id/food-orders", controllers.GetShowFoodOrders(config.DB))
+		staff.POST("/food-orders/collect", controllers.CollectFoodOrder(config.DB))
 	}
 
 	// Public HTML Pages
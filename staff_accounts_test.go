@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cineverse/config"
+	"cineverse/controllers"
+	"cineverse/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestStaffAccountLogin opens a staff account as an admin and logs in with it:
+// the token must carry the staff role until the account is promoted to admin.
+func TestStaffAccountLogin(t *testing.T) {
+	db := testDB(t)
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_SECRET", "test-secret")
+	config.DB = db
+
+	email := fmt.Sprintf("staff-%d@example.com", time.Now().UnixNano())
+	t.Cleanup(func() {
+		var account models.Admin
+		if db.Where("email = ?", email).First(&account).Error == nil {
+			db.Unscoped().Delete(&account)
+		}
+	})
+
+	router := gin.New()
+	router.POST("/staff", controllers.AdminCreateStaff(db))
+	router.PUT("/staff/:id/role", controllers.AdminSetAccountRole(db))
+	router.POST("/login", controllers.AdminLogin)
+
+	send := func(method, path string, body gin.H) (int, map[string]interface{}) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	login := func() string {
+		code, resp := send(http.MethodPost, "/login", gin.H{"email": email, "password": "gate-pass"})
+		if code != http.StatusOK {
+			t.Fatalf("login: status %d, body %v", code, resp)
+		}
+		return fmt.Sprint(resp["role"])
+	}
+
+	code, resp := send(http.MethodPost, "/staff", gin.H{"full_name": "Gate Staff", "email": email, "password": "gate-pass"})
+	if code != http.StatusCreated {
+		t.Fatalf("create staff: status %d, body %v", code, resp)
+	}
+	if code, resp := send(http.MethodPost, "/staff", gin.H{"full_name": "Gate Staff", "email": email, "password": "gate-pass"}); code != http.StatusConflict {
+		t.Fatalf("duplicate staff: status %d, body %v", code, resp)
+	}
+	if role := login(); role != "staff" {
+		t.Fatalf("staff login got role %q, want staff", role)
+	}
+
+	account := resp["account"].(map[string]interface{})
+	path := fmt.Sprintf("/staff/%v/role", account["id"])
+	if code, resp := send(http.MethodPut, path, gin.H{"role": "owner"}); code != http.StatusBadRequest {
+		t.Fatalf("invalid role: status %d, body %v", code, resp)
+	}
+	if code, resp := send(http.MethodPut, path, gin.H{"role": "admin"}); code != http.StatusOK {
+		t.Fatalf("promote: status %d, body %v", code, resp)
+	}
+	if role := login(); role != "admin" {
+		t.Fatalf("promoted login got role %q, want admin", role)
+	}
+}
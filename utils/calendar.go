@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// CalendarEvent is one VEVENT of an iCalendar (RFC 5545) document.
+type CalendarEvent struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Location    string
+	Description string
+	Cancelled   bool // exported as STATUS:CANCELLED so subscribed calendars drop it
+	Updated     time.Time
+}
+
+const icalTimeLayout = "20060102T150405Z"
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// BuildCalendar renders events as an iCalendar document named name.
+func BuildCalendar(name string, events []CalendarEvent) []byte {
+	var b strings.Builder
+	line := func(s string) {
+		// Content lines are folded at 75 octets without splitting UTF-8 sequences
+		for len(s) > 75 {
+			cut := 75
+			for cut > 0 && s[cut]&0xC0 == 0x80 {
+				cut--
+			}
+			b.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		b.WriteString(s + "\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//CineVerse//Bookings//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + icalEscaper.Replace(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	line("X-PUBLISHED-TTL:PT1H")
+
+	now := time.Now().UTC().Format(icalTimeLayout)
+	for _, event := range events {
+		status, sequence := "CONFIRMED", "0"
+		if event.Cancelled {
+			status, sequence = "CANCELLED", "1"
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + now)
+		line("DTSTART:" + event.Start.UTC().Format(icalTimeLayout))
+		line("DTEND:" + event.End.UTC().Format(icalTimeLayout))
+		line("SUMMARY:" + icalEscaper.Replace(event.Summary))
+		if event.Location != "" {
+			line("LOCATION:" + icalEscaper.Replace(event.Location))
+		}
+		if event.Description != "" {
+			line("DESCRIPTION:" + icalEscaper.Replace(event.Description))
+		}
+		line("STATUS:" + status)
+		line("SEQUENCE:" + sequence)
+		if !event.Updated.IsZero() {
+			line("LAST-MODIFIED:" + event.Updated.UTC().Format(icalTimeLayout))
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// CalendarFeedTokenHash is what is stored for a calendar feed token.
+func CalendarFeedTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
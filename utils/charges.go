@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ChargeBase is what the taxes and fees of a booking are worked out on.
+type ChargeBase struct {
+	Tickets float64 // seat subtotal less discounts
+	Parking float64
+	Seats   int
+}
+
+// Charges are the tax and fee lines of a booking. Fees and Taxes sum the
+// exclusive lines, the amounts added to the price.
+type Charges struct {
+	Lines []models.BookingCharge
+	Fees  float64
+	Taxes float64
+}
+
+// Added is what the charges add to the price.
+func (c *Charges) Added() float64 {
+	return RoundMoney(c.Fees + c.Taxes)
+}
+
+// LoadTaxFees returns the active taxes and fees of a show's theatre, in bill
+// order. A theatre's own rule replaces a global rule of the same kind and name.
+func LoadTaxFees(db *gorm.DB, show *models.Show) ([]models.TaxFee, error) {
+	var rules []models.TaxFee
+	if err := db.Where("active = ?", true).
+		Where("theatre_id IS NULL OR theatre_id = (SELECT theatre_id FROM screens WHERE screens.id = ?)", show.ScreenID).
+		Order("position, id").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.TheatreID != nil {
+			local[rule.Kind+"\x00"+rule.Name] = true
+		}
+	}
+	applicable := rules[:0]
+	for _, rule := range rules {
+		if rule.TheatreID == nil && local[rule.Kind+"\x00"+rule.Name] {
+			continue
+		}
+		applicable = append(applicable, rule)
+	}
+	return applicable, nil
+}
+
+// ValidateTaxFee checks the kind, base and rate of a rule.
+func ValidateTaxFee(rule *models.TaxFee) error {
+	switch {
+	case rule.Name == "":
+		return errors.New("name is required")
+	case rule.Kind != "tax" && rule.Kind != "fee":
+		return errors.New(`kind must be "tax" or "fee"`)
+	case rule.AppliesTo != "tickets" && rule.AppliesTo != "parking":
+		return errors.New(`applies_to must be "tickets" or "parking"`)
+	case rule.Kind == "fee" && rule.AppliesTo != "tickets":
+		return errors.New("fees are charged on tickets")
+	case rule.AppliesTo == "parking" && !rule.Inclusive:
+		return errors.New("taxes on parking must be inclusive: parking fees are final prices")
+	case rule.RateType != "percent" && rule.RateType != "per_seat":
+		return errors.New(`rate type must be "percent" or "per_seat"`)
+	case rule.Rate <= 0:
+		return errors.New("rate must be positive")
+	case rule.RateType == "percent" && rule.Rate > 100:
+		return errors.New("a percent rate cannot exceed 100")
+	}
+	return nil
+}
+
+// ComputeCharges works out the tax and fee lines of a booking. Fees are charged on
+// the tickets first; taxes on tickets then apply to the tickets together with the
+// fees added to them. Inclusive percent fees, and inclusive percent taxes on the
+// same base, share one divisor, so that together they account for exactly the
+// amount inside the price.
+func ComputeCharges(rules []models.TaxFee, base ChargeBase) *Charges {
+	charges := &Charges{}
+	line := func(rule *models.TaxFee, on, amount float64) {
+		amount = RoundMoney(amount)
+		if amount <= 0 {
+			return
+		}
+		ruleID := rule.ID
+		charges.Lines = append(charges.Lines, models.BookingCharge{
+			TaxFeeID:  &ruleID,
+			Kind:      rule.Kind,
+			Name:      rule.Name,
+			AppliesTo: rule.AppliesTo,
+			RateType:  rule.RateType,
+			Rate:      rule.Rate,
+			Inclusive: rule.Inclusive,
+			Base:      RoundMoney(on),
+			Amount:    amount,
+		})
+		if !rule.Inclusive {
+			if rule.Kind == "fee" {
+				charges.Fees = RoundMoney(charges.Fees + amount)
+			} else {
+				charges.Taxes = RoundMoney(charges.Taxes + amount)
+			}
+		}
+	}
+
+	var inclusiveFeeRate float64
+	for _, rule := range rules {
+		if rule.Kind == "fee" && rule.Inclusive && rule.RateType == "percent" {
+			inclusiveFeeRate += rule.Rate
+		}
+	}
+	for i := range rules {
+		if rule := &rules[i]; rule.Kind == "fee" {
+			switch {
+			case rule.RateType == "percent" && rule.Inclusive:
+				line(rule, base.Tickets, base.Tickets*rule.Rate/(100+inclusiveFeeRate))
+			case rule.RateType == "percent":
+				line(rule, base.Tickets, base.Tickets*rule.Rate/100)
+			default:
+				line(rule, base.Tickets, rule.Rate*float64(base.Seats))
+			}
+		}
+	}
+
+	bases := map[string]float64{"tickets": base.Tickets + charges.Fees, "parking": base.Parking}
+	units := map[string]int{"tickets": base.Seats, "parking": 0}
+	if base.Parking > 0 {
+		units["parking"] = 1
+	}
+	inclusiveRate := make(map[string]float64)
+	for _, rule := range rules {
+		if rule.Kind == "tax" && rule.Inclusive && rule.RateType == "percent" {
+			inclusiveRate[rule.AppliesTo] += rule.Rate
+		}
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Kind != "tax" {
+			continue
+		}
+		on := bases[rule.AppliesTo]
+		switch {
+		case rule.RateType == "per_seat":
+			line(rule, on, rule.Rate*float64(units[rule.AppliesTo]))
+		case rule.Inclusive:
+			line(rule, on, on*rule.Rate/(100+inclusiveRate[rule.AppliesTo]))
+		default:
+			line(rule, on, on*rule.Rate/100)
+		}
+	}
+	return charges
+}
+
+// CalculateCharges works out the taxes and fees of a booking of a show.
+func CalculateCharges(db *gorm.DB, show *models.Show, base ChargeBase) (*Charges, error) {
+	rules, err := LoadTaxFees(db, show)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeCharges(rules, base), nil
+}
+
+// SaveCharges replaces the tax and fee lines of a booking and its totals of them.
+func SaveCharges(tx *gorm.DB, bookingID uint, charges *Charges) error {
+	if err := tx.Where("booking_id = ?", bookingID).Delete(&models.BookingCharge{}).Error; err != nil {
+		return err
+	}
+	for i := range charges.Lines {
+		charges.Lines[i].ID = 0
+		charges.Lines[i].BookingID = bookingID
+	}
+	if len(charges.Lines) > 0 {
+		if err := tx.Create(&charges.Lines).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.Booking{}).Where("id = ?", bookingID).
+		Updates(map[string]interface{}{"fees": charges.Fees, "taxes": charges.Taxes}).Error
+}
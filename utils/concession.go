@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxFoodQuantity caps the quantity of one item in an order.
+const maxFoodQuantity = 20
+
+// ErrFoodUnavailable is wrapped by every reason a food order cannot be placed.
+var ErrFoodUnavailable = errors.New("food order cannot be placed")
+
+// FoodLine is an item and quantity asked for in a food order.
+type FoodLine struct {
+	ItemID   uint `json:"item_id"`
+	Quantity int  `json:"quantity"`
+}
+
+// GeneratePickupCode returns a random code such as F-7KQM-X2PA.
+func GeneratePickupCode() (string, error) {
+	return randomCode("F", 2)
+}
+
+// ValidateConcessionItem checks the price and stock of an item and that the
+// items of a combo are plain items of the same theatre.
+func ValidateConcessionItem(tx *gorm.DB, item *models.ConcessionItem) error {
+	switch {
+	case item.Name == "":
+		return errors.New("name is required")
+	case item.Price <= 0:
+		return errors.New("price must be positive")
+	case item.Stock != nil && *item.Stock < 0:
+		return errors.New("stock cannot be negative")
+	}
+	if len(item.Components) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(item.Components))
+	for _, entry := range item.Components {
+		if entry.Quantity <= 0 {
+			return errors.New("combo quantities must be positive")
+		}
+		if item.ID != 0 && entry.ItemID == item.ID {
+			return errors.New("a combo cannot contain itself")
+		}
+		ids = append(ids, entry.ItemID)
+	}
+	var components []models.ConcessionItem
+	if err := tx.Where("id IN ? AND theatre_id = ?", ids, item.TheatreID).Find(&components).Error; err != nil {
+		return err
+	}
+	found := make(map[uint]bool)
+	for _, component := range components {
+		if len(component.Components) > 0 {
+			return fmt.Errorf("%s is a combo itself", component.Name)
+		}
+		found[component.ID] = true
+	}
+	for _, entry := range item.Components {
+		if !found[entry.ItemID] {
+			return fmt.Errorf("item %d is not on this theatre's menu", entry.ItemID)
+		}
+	}
+	return nil
+}
+
+// PlaceFoodOrder creates a food order for a booking at a theatre and takes the
+// stock it needs, locking the items in ID order so concurrent orders cannot
+// oversell them. Prices are those of the menu now.
+func PlaceFoodOrder(tx *gorm.DB, booking *models.Booking, theatreID uint, lines []FoodLine, status string) (*models.FoodOrder, error) {
+	quantities, order, err := foodQuantities(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.ConcessionItem
+	if err := tx.Where("id IN ? AND theatre_id = ? AND active = ?", order, theatreID, true).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.ConcessionItem)
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	// the stock each line takes: the item itself, or the items of a combo
+	taken := make(map[uint][]models.ComboEntry)
+	need := make(map[uint]int)
+	for _, id := range order {
+		item, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: item %d is not on the menu", ErrFoodUnavailable, id)
+		}
+		entries := []models.ComboEntry{{ItemID: id, Quantity: quantities[id]}}
+		if len(item.Components) > 0 {
+			entries = entries[:0]
+			for _, component := range item.Components {
+				entries = append(entries, models.ComboEntry{ItemID: component.ItemID, Quantity: component.Quantity * quantities[id]})
+			}
+		}
+		for _, entry := range entries {
+			need[entry.ItemID] += entry.Quantity
+		}
+		taken[id] = entries
+	}
+
+	stockIDs := make([]uint, 0, len(need))
+	for id := range need {
+		stockIDs = append(stockIDs, id)
+	}
+	sort.Slice(stockIDs, func(i, j int) bool { return stockIDs[i] < stockIDs[j] })
+	var stocked []models.ConcessionItem
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND stock IS NOT NULL", stockIDs).Order("id").Find(&stocked).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range stocked {
+		if *item.Stock < need[item.ID] {
+			return nil, fmt.Errorf("%w: only %d %s left", ErrFoodUnavailable, *item.Stock, item.Name)
+		}
+		if err := tx.Model(&item).Update("stock", gorm.Expr("stock - ?", need[item.ID])).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	code, err := GeneratePickupCode()
+	if err != nil {
+		return nil, err
+	}
+	foodOrder := models.FoodOrder{BookingID: booking.ID, ShowID: booking.ShowID, PickupCode: code, Status: status}
+	for _, id := range order {
+		item, itemID := byID[id], id
+		amount := RoundMoney(item.Price * float64(quantities[id]))
+		foodOrder.Items = append(foodOrder.Items, models.FoodOrderItem{
+			ItemID:    &itemID,
+			Name:      item.Name,
+			UnitPrice: item.Price,
+			Quantity:  quantities[id],
+			Amount:    amount,
+			Taken:     taken[id],
+		})
+		foodOrder.Total = RoundMoney(foodOrder.Total + amount)
+	}
+	if err := tx.Create(&foodOrder).Error; err != nil {
+		return nil, err
+	}
+	return &foodOrder, nil
+}
+
+// foodQuantities adds up the quantity asked for of each item, returning the items
+// in the order they were first asked for.
+func foodQuantities(lines []FoodLine) (map[uint]int, []uint, error) {
+	quantities := make(map[uint]int)
+	var order []uint
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, nil, fmt.Errorf("%w: quantities must be positive", ErrFoodUnavailable)
+		}
+		if _, seen := quantities[line.ItemID]; !seen {
+			order = append(order, line.ItemID)
+		}
+		quantities[line.ItemID] += line.Quantity
+		if quantities[line.ItemID] > maxFoodQuantity {
+			return nil, nil, fmt.Errorf("%w: at most %d of an item", ErrFoodUnavailable, maxFoodQuantity)
+		}
+	}
+	if len(order) == 0 {
+		return nil, nil, fmt.Errorf("%w: no items", ErrFoodUnavailable)
+	}
+	return quantities, order, nil
+}
+
+// FoodOrderTotal prices a food order at a theatre's menu without placing it, for
+// charging a paid booking before the order is placed. Stock is only checked by
+// PlaceFoodOrder.
+func FoodOrderTotal(db *gorm.DB, theatreID uint, lines []FoodLine) (float64, error) {
+	quantities, order, err := foodQuantities(lines)
+	if err != nil {
+		return 0, err
+	}
+	var items []models.ConcessionItem
+	if err := db.Where("id IN ? AND theatre_id = ? AND active = ?", order, theatreID, true).Find(&items).Error; err != nil {
+		return 0, err
+	}
+	if len(items) != len(order) {
+		return 0, fmt.Errorf("%w: an item is not on the menu", ErrFoodUnavailable)
+	}
+	var total float64
+	for _, item := range items {
+		total = RoundMoney(total + RoundMoney(item.Price*float64(quantities[item.ID])))
+	}
+	return total, nil
+}
+
+// UncollectedFoodAmount sums the food orders of a booking that are still to be
+// collected, the food a cancellation can give back.
+func UncollectedFoodAmount(db *gorm.DB, bookingID uint) (float64, error) {
+	var amount float64
+	err := db.Model(&models.FoodOrder{}).Where("booking_id = ? AND status IN ?", bookingID, []string{"pending", "confirmed"}).
+		Select("COALESCE(SUM(total), 0)").Scan(&amount).Error
+	return RoundMoney(amount), err
+}
+
+// ConfirmFoodOrders marks the pending food orders of a paid booking confirmed,
+// ready for the counter to prepare.
+func ConfirmFoodOrders(tx *gorm.DB, bookingID uint) error {
+	return tx.Model(&models.FoodOrder{}).Where("booking_id = ? AND status = ?", bookingID, "pending").
+		Update("status", "confirmed").Error
+}
+
+// CancelFoodOrders cancels the food orders of bookings that were not collected
+// and gives their stock back.
+func CancelFoodOrders(tx *gorm.DB, bookingIDs []uint) error {
+	var orders []models.FoodOrder
+	if err := tx.Preload("Items").Where("booking_id IN ? AND status IN ?", bookingIDs, []string{"pending", "confirmed"}).
+		Find(&orders).Error; err != nil || len(orders) == 0 {
+		return err
+	}
+
+	back := make(map[uint]int)
+	ids := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+		for _, item := range order.Items {
+			for _, entry := range item.Taken {
+				back[entry.ItemID] += entry.Quantity
+			}
+		}
+	}
+	if err := tx.Model(&models.FoodOrder{}).Where("id IN ?", ids).Update("status", "cancelled").Error; err != nil {
+		return err
+	}
+
+	itemIDs := make([]uint, 0, len(back))
+	for id := range back {
+		itemIDs = append(itemIDs, id)
+	}
+	sort.Slice(itemIDs, func(i, j int) bool { return itemIDs[i] < itemIDs[j] })
+	for _, id := range itemIDs {
+		if err := tx.Model(&models.ConcessionItem{}).Where("id = ? AND stock IS NOT NULL", id).
+			Update("stock", gorm.Expr("stock + ?", back[id])).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const fakeSignatureHeader = "X-Fake-Signature"
+
+// fakeIntent is the fake gateway's view of one payment.
+type fakeIntent struct {
+	amount   float64
+	captured float64
+	refunded float64
+	status   string // "requires_payment", "authorized", "failed", "captured"
+}
+
+// FakeGateway is an in-process gateway for local development. Each intent is
+// decided after a delay and reported through a signed webhook to this server,
+// exactly like a real provider would. It is configured from the environment:
+//
+//	FAKE_GATEWAY_SUCCESS_RATE=100      percent of payments that are authorized
+//	FAKE_GATEWAY_DELAY_SECONDS=2       time before the webhook is sent
+//	FAKE_GATEWAY_WEBHOOK_URL           defaults to http://localhost:$PORT/api/payments/webhook/fake
+//	FAKE_GATEWAY_WEBHOOK_SECRET        required, and not the same as JWT_SECRET
+//
+// Intents live in memory, so a restart forgets them.
+type FakeGateway struct {
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+	client  *http.Client
+}
+
+func init() {
+	RegisterPaymentGateway(NewFakeGateway())
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		intents: make(map[string]*fakeIntent),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *FakeGateway) Name() string { return "fake" }
+
+func (g *FakeGateway) successRate() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("FAKE_GATEWAY_SUCCESS_RATE"), 64)
+	if err != nil {
+		return 100
+	}
+	return math.Max(0, math.Min(100, rate))
+}
+
+func (g *FakeGateway) delay() time.Duration {
+	seconds, err := strconv.ParseFloat(os.Getenv("FAKE_GATEWAY_DELAY_SECONDS"), 64)
+	if err != nil || seconds < 0 {
+		seconds = 2
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (g *FakeGateway) webhookURL() string {
+	if url := os.Getenv("FAKE_GATEWAY_WEBHOOK_URL"); url != "" {
+		return url
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port + "/api/payments/webhook/fake"
+}
+
+// secret is empty while no dedicated webhook secret is configured, which makes
+// VerifyWebhookSignature turn every webhook away.
+func (g *FakeGateway) secret() string {
+	secret, err := dedicatedSecret("FAKE_GATEWAY_WEBHOOK_SECRET")
+	if err != nil {
+		log.Printf("fake gateway: %v", err)
+		return ""
+	}
+	return secret
+}
+
+func (g *FakeGateway) succeeds() bool {
+	return mrand.Float64()*100 < g.successRate()
+}
+
+func fakeID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+func (g *FakeGateway) CreateIntent(amount float64, method, reference string) (*PaymentIntent, error) {
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	id := fakeID("pi_fake_")
+	g.mu.Lock()
+	g.intents[id] = &fakeIntent{amount: RoundMoney(amount), status: "requires_payment"}
+	g.mu.Unlock()
+
+	go g.process(id)
+	return &PaymentIntent{ID: id}, nil
+}
+
+// process plays the customer paying: after the delay the intent is authorized or
+// declined and the outcome is sent to the webhook.
+func (g *FakeGateway) process(id string) {
+	time.Sleep(g.delay())
+
+	g.mu.Lock()
+	intent, ok := g.intents[id]
+	if !ok || intent.status != "requires_payment" {
+		// Already charged off-session
+		g.mu.Unlock()
+		return
+	}
+	event := WebhookEvent{ID: fakeID("evt_fake_"), IntentID: id, Amount: intent.amount}
+	if g.succeeds() {
+		intent.status = "authorized"
+		event.Type = WebhookPaymentAuthorized
+	} else {
+		intent.status = "failed"
+		event.Type = WebhookPaymentFailed
+		event.Reason = "card_declined"
+	}
+	g.mu.Unlock()
+
+	payload, _ := json.Marshal(event)
+	for attempt := 1; attempt <= 3; attempt++ {
+		err := g.send(payload)
+		if err == nil {
+			return
+		}
+		log.Printf("fake gateway: webhook %s attempt %d failed: %v", event.ID, attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+func (g *FakeGateway) send(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, g.webhookURL(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fakeSignatureHeader, SignWebhook(g.secret(), payload, time.Now()))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (g *FakeGateway) Capture(intentID string, amount float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return errors.New("unknown payment intent")
+	}
+	if RoundMoney(amount) > intent.amount {
+		return errors.New("capture exceeds the authorized amount")
+	}
+
+	switch intent.status {
+	case "requires_payment":
+		// Off-session charge of the saved payment method
+		if !g.succeeds() {
+			intent.status = "failed"
+			return errors.New("card_declined")
+		}
+	case "authorized":
+	case "captured":
+		if RoundMoney(amount) == intent.captured {
+			return nil
+		}
+		return errors.New("intent was captured for a different amount")
+	default:
+		return fmt.Errorf("cannot capture a %s payment", intent.status)
+	}
+
+	intent.status = "captured"
+	intent.captured = RoundMoney(amount)
+	return nil
+}
+
+func (g *FakeGateway) Refund(intentID string, amount float64, reason string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentID]
+	if !ok {
+		// Forgotten across a restart; the fake has no money to lose
+		return fakeID("re_fake_"), nil
+	}
+	if intent.status != "captured" {
+		return "", fmt.Errorf("cannot refund a %s payment", intent.status)
+	}
+	if RoundMoney(intent.refunded+amount) > intent.captured {
+		return "", errors.New("refund exceeds the captured amount")
+	}
+
+	intent.refunded = RoundMoney(intent.refunded + amount)
+	return fakeID("re_fake_"), nil
+}
+
+func (g *FakeGateway) VerifyWebhook(payload []byte, headers http.Header) (*WebhookEvent, error) {
+	if err := VerifyWebhookSignature(g.secret(), payload, headers.Get(fakeSignatureHeader)); err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.ID == "" || event.IntentID == "" {
+		return nil, errors.New("incomplete webhook event")
+	}
+	return &event, nil
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"cineverse/models"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrGiftCardUnusable is wrapped by every reason a gift card cannot be redeemed.
+var ErrGiftCardUnusable = errors.New("gift card cannot be redeemed")
+
+// codeAlphabet leaves out characters that are easily misread.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// randomCode returns prefix followed by groups of four random characters, each
+// group after a dash.
+func randomCode(prefix string, groups int) (string, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i := 0; i < groups*4; i++ {
+		if i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// GenerateGiftCardCode returns a random code such as GC-7KQM-X2PA-9RTD.
+func GenerateGiftCardCode() (string, error) {
+	return randomCode("GC", 3)
+}
+
+// IssueGiftCard creates a gift card and funds its ledger account.
+func IssueGiftCard(tx *gorm.DB, amount float64, expiresAt *time.Time, note string, adminID *uint) (*models.GiftCard, error) {
+	code, err := GenerateGiftCardCode()
+	if err != nil {
+		return nil, err
+	}
+	card := models.GiftCard{Code: code, Amount: RoundMoney(amount), Note: note, AdminID: adminID, ExpiresAt: expiresAt}
+	if err := tx.Create(&card).Error; err != nil {
+		return nil, err
+	}
+
+	account, err := ledgerAccount(tx, models.LedgerAccount{
+		Code:       "gift_card:" + strconv.FormatUint(uint64(card.ID), 10),
+		Kind:       "gift_card",
+		GiftCardID: &card.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := SystemAccount(tx, LedgerGiftCards)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := PostLedger(tx, models.LedgerTransaction{Type: LedgerGiftCardIssue, Reference: card.Code, Note: note},
+		LedgerLeg{issuer.ID, -card.Amount}, LedgerLeg{account.ID, card.Amount}); err != nil {
+		return nil, err
+	}
+	account.Balance = card.Amount
+	card.Account = account
+	return &card, nil
+}
+
+// RedeemGiftCard moves the whole remaining value of a gift card into a user's wallet.
+func RedeemGiftCard(tx *gorm.DB, code string, userID uint) (*models.GiftCard, *models.LedgerTransaction, error) {
+	var card models.GiftCard
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("%w: unknown code", ErrGiftCardUnusable)
+		}
+		return nil, nil, err
+	}
+	if card.RedeemedAt != nil {
+		return nil, nil, fmt.Errorf("%w: already redeemed", ErrGiftCardUnusable)
+	}
+	if card.ExpiresAt != nil && time.Now().After(*card.ExpiresAt) {
+		return nil, nil, fmt.Errorf("%w: expired on %s", ErrGiftCardUnusable, card.ExpiresAt.Format("02 Jan 2006"))
+	}
+
+	var account models.LedgerAccount
+	if err := tx.Where("gift_card_id = ?", card.ID).First(&account).Error; err != nil {
+		return nil, nil, err
+	}
+	wallet, err := WalletAccount(tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := PostLedger(tx, models.LedgerTransaction{Type: LedgerGiftCardRedeem, Reference: card.Code},
+		LedgerLeg{account.ID, -account.Balance}, LedgerLeg{wallet.ID, account.Balance})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&card).Updates(map[string]interface{}{"redeemed_by": userID, "redeemed_at": now}).Error; err != nil {
+		return nil, nil, err
+	}
+	card.RedeemedBy, card.RedeemedAt = &userID, &now
+	return &card, entry, nil
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultGSTRatePercent = 18
+
+// GSTRate returns the GST percentage included in ticket and parking prices of
+// bookings made without configured taxes, and the rate of the default GST rule.
+// It is read from GST_RATE_PERCENT and falls back to 18.
+func GSTRate() float64 {
+	rate, err := strconv.ParseFloat(os.Getenv("GST_RATE_PERCENT"), 64)
+	if err != nil || rate < 0 {
+		rate = defaultGSTRatePercent
+	}
+	return rate
+}
+
+// IssueInvoice returns the invoice of a booking, issuing it with the next number
+// in sequence if it has none yet. The invoices table is locked while numbering
+// so concurrent confirmations never share or skip a number; call it inside the
+// transaction that confirms the booking.
+func IssueInvoice(tx *gorm.DB, booking *models.Booking) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := tx.Where("booking_id = ? AND food_order_id IS NULL", booking.ID).First(&invoice).Error
+	if err == nil {
+		return &invoice, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	last, err := lastInvoiceSequence(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// food invoiced on its own is left out
+	var supplementary float64
+	if err := tx.Model(&models.Invoice{}).Where("booking_id = ? AND food_order_id IS NOT NULL", booking.ID).
+		Select("COALESCE(SUM(total), 0)").Scan(&supplementary).Error; err != nil {
+		return nil, err
+	}
+	food := RoundMoney(booking.FoodAmount - supplementary)
+
+	var lines []models.BookingCharge
+	if err := tx.Where("booking_id = ? AND kind = ?", booking.ID, "tax").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	// points are a means of payment: the invoice covers what they paid too
+	total := RoundMoney(booking.TotalAmount + booking.PointsValue - supplementary)
+	var taxable, rate float64
+	if len(lines) > 0 {
+		// the taxes charged on the booking, split evenly between CGST and SGST
+		var tax float64
+		for _, line := range lines {
+			tax += line.Amount
+		}
+		taxable = RoundMoney(total - tax)
+		if taxable > 0 {
+			rate = RoundMoney(tax / taxable * 100)
+		}
+	} else {
+		// booked without configured taxes: prices include GST at the default rate
+		rate = GSTRate()
+		taxable = RoundMoney(total / (1 + rate/100))
+	}
+	cgst := RoundMoney((total - taxable) / 2)
+
+	invoice = models.Invoice{
+		BookingID:    booking.ID,
+		Sequence:     last + 1,
+		Number:       fmt.Sprintf("INV-%d-%06d", now.Year(), last+1),
+		SeatSubtotal: RoundMoney(total - booking.ParkingFee - food - booking.Fees - booking.Taxes + booking.Discount),
+		Discount:     RoundMoney(booking.Discount),
+		Fees:         RoundMoney(booking.Fees),
+		ParkingFee:   RoundMoney(booking.ParkingFee),
+		Food:         food,
+		PointsPaid:   RoundMoney(booking.PointsValue),
+		TaxRate:      rate,
+		TaxableValue: taxable,
+		CGST:         cgst,
+		SGST:         RoundMoney(total - taxable - cgst),
+		Total:        total,
+		IssuedAt:     now,
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// IssueFoodInvoice issues the supplementary invoice of a food order placed after
+// its booking was paid. Food is taxed as on the booking's own invoice: it adds
+// to the taxable value of a booking with configured taxes, and otherwise
+// includes GST at the default rate. Call it inside the transaction that charges
+// the order.
+func IssueFoodInvoice(tx *gorm.DB, booking *models.Booking, order *models.FoodOrder) (*models.Invoice, error) {
+	last, err := lastInvoiceSequence(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var taxLines int64
+	if err := tx.Model(&models.BookingCharge{}).Where("booking_id = ? AND kind = ?", booking.ID, "tax").Count(&taxLines).Error; err != nil {
+		return nil, err
+	}
+	total := RoundMoney(order.Total)
+	taxable, rate := total, 0.0
+	if taxLines == 0 {
+		rate = GSTRate()
+		taxable = RoundMoney(total / (1 + rate/100))
+	}
+	cgst := RoundMoney((total - taxable) / 2)
+
+	now := time.Now()
+	orderID := order.ID
+	invoice := models.Invoice{
+		BookingID:    booking.ID,
+		FoodOrderID:  &orderID,
+		Sequence:     last + 1,
+		Number:       fmt.Sprintf("INV-%d-%06d", now.Year(), last+1),
+		Food:         total,
+		TaxRate:      rate,
+		TaxableValue: taxable,
+		CGST:         cgst,
+		SGST:         RoundMoney(total - taxable - cgst),
+		Total:        total,
+		IssuedAt:     now,
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// lastInvoiceSequence locks the invoices table for numbering and returns the last
+// number issued.
+func lastInvoiceSequence(tx *gorm.DB) (uint, error) {
+	if err := tx.Exec("LOCK TABLE invoices IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+		return 0, err
+	}
+	var last uint
+	err := tx.Model(&models.Invoice{}).Select("COALESCE(MAX(sequence), 0)").Scan(&last).Error
+	return last, err
+}
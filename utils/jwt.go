@@ -13,6 +13,19 @@ import (
 	"gorm.io/gorm"
 )
 
+// dedicatedSecret reads a signing secret that must not be the login secret, so a
+// leaked JWT_SECRET cannot also forge what the key signs.
+func dedicatedSecret(key string) (string, error) {
+	secret := os.Getenv(key)
+	if secret == "" {
+		return "", errors.New(key + " is not set")
+	}
+	if secret == os.Getenv("JWT_SECRET") {
+		return "", errors.New(key + " must differ from JWT_SECRET")
+	}
+	return secret, nil
+}
+
 type MyClaims struct {
 	UserID uint   `json:"userId"`
 	Role   string `json:"role"`
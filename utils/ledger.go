@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// System ledger accounts. Value enters and leaves the ledger through them, so
+// their balances are usually negative or positive totals rather than holdings.
+const (
+	LedgerGateway   = "gateway"    // money received from or paid back through payment gateways
+	LedgerSales     = "sales"      // bookings paid from wallets, and refunds of bookings into wallets
+	LedgerGiftCards = "gift_cards" // value created by issuing gift cards
+)
+
+// Ledger transaction types.
+const (
+	LedgerTopup          = "topup"
+	LedgerPayment        = "payment"
+	LedgerCharge         = "charge" // extra charge of a seat exchange or a later food order
+	LedgerRefund         = "refund"
+	LedgerGiftCardIssue  = "gift_card_issue"
+	LedgerGiftCardRedeem = "gift_card_redeem"
+)
+
+// WalletGateway is the gateway name of payments made from the customer's wallet.
+const WalletGateway = "wallet"
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// LedgerLeg is one side of a ledger transaction.
+type LedgerLeg struct {
+	AccountID uint
+	Amount    float64 // positive credits, negative debits
+}
+
+// ledgerAccount finds an account by code, creating it if it does not exist yet.
+func ledgerAccount(tx *gorm.DB, account models.LedgerAccount) (*models.LedgerAccount, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+	var existing models.LedgerAccount
+	if err := tx.Where("code = ?", account.Code).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// SystemAccount returns one of the system ledger accounts.
+func SystemAccount(tx *gorm.DB, name string) (*models.LedgerAccount, error) {
+	return ledgerAccount(tx, models.LedgerAccount{Code: "system:" + name, Kind: "system"})
+}
+
+// WalletAccount returns a user's wallet, opening it on first use.
+func WalletAccount(tx *gorm.DB, userID uint) (*models.LedgerAccount, error) {
+	return ledgerAccount(tx, models.LedgerAccount{Code: "wallet:" + strconv.FormatUint(uint64(userID), 10), Kind: "wallet", UserID: &userID})
+}
+
+// PostLedger records a balanced transaction and applies it to the account
+// balances. Accounts are locked in ID order, so concurrent postings cannot
+// deadlock or overdraw a wallet or gift card.
+func PostLedger(tx *gorm.DB, entry models.LedgerTransaction, legs ...LedgerLeg) (*models.LedgerTransaction, error) {
+	var sum float64
+	ids := make([]uint, 0, len(legs))
+	for i := range legs {
+		legs[i].Amount = RoundMoney(legs[i].Amount)
+		sum += legs[i].Amount
+		ids = append(ids, legs[i].AccountID)
+	}
+	if len(legs) < 2 || RoundMoney(sum) != 0 {
+		return nil, fmt.Errorf("unbalanced ledger transaction %s: legs sum to %.2f", entry.Type, sum)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var accounts []models.LedgerAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.LedgerAccount)
+	for i := range accounts {
+		byID[accounts[i].ID] = &accounts[i]
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	for _, leg := range legs {
+		account, ok := byID[leg.AccountID]
+		if !ok {
+			return nil, fmt.Errorf("ledger account %d not found", leg.AccountID)
+		}
+		account.Balance = RoundMoney(account.Balance + leg.Amount)
+		if account.Kind != "system" && account.Balance < 0 {
+			return nil, fmt.Errorf("%w: %.2f available", ErrInsufficientBalance, account.Balance-leg.Amount)
+		}
+		if err := tx.Model(account).Update("balance", account.Balance).Error; err != nil {
+			return nil, err
+		}
+		line := models.LedgerEntry{TransactionID: entry.ID, AccountID: account.ID, Amount: leg.Amount, BalanceAfter: account.Balance}
+		if err := tx.Create(&line).Error; err != nil {
+			return nil, err
+		}
+		entry.Entries = append(entry.Entries, line)
+	}
+	return &entry, nil
+}
+
+// MoveToWallet credits a user's wallet from a system account.
+func MoveToWallet(tx *gorm.DB, userID uint, from string, amount float64, entry models.LedgerTransaction) (*models.LedgerTransaction, error) {
+	wallet, err := WalletAccount(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	system, err := SystemAccount(tx, from)
+	if err != nil {
+		return nil, err
+	}
+	return PostLedger(tx, entry, LedgerLeg{system.ID, -amount}, LedgerLeg{wallet.ID, amount})
+}
+
+// MoveFromWallet debits a user's wallet into a system account. It fails with
+// ErrInsufficientBalance if the wallet does not hold enough.
+func MoveFromWallet(tx *gorm.DB, userID uint, to string, amount float64, entry models.LedgerTransaction) (*models.LedgerTransaction, error) {
+	wallet, err := WalletAccount(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	system, err := SystemAccount(tx, to)
+	if err != nil {
+		return nil, err
+	}
+	return PostLedger(tx, entry, LedgerLeg{wallet.ID, -amount}, LedgerLeg{system.ID, amount})
+}
+
+// LedgerAuditIssue is an inconsistency found by AuditLedger.
+type LedgerAuditIssue struct {
+	AccountID     uint    `json:"account_id,omitempty"`
+	TransactionID uint    `json:"transaction_id,omitempty"`
+	Problem       string  `json:"problem"`
+	Expected      float64 `json:"expected"`
+	Actual        float64 `json:"actual"`
+}
+
+// AuditLedger checks that every transaction balances, that every account's
+// balance equals the sum of its entries, and that no wallet or gift card is
+// overdrawn.
+func AuditLedger(db *gorm.DB) ([]LedgerAuditIssue, error) {
+	issues := []LedgerAuditIssue{}
+
+	var unbalanced []struct {
+		TransactionID uint
+		Total         float64
+	}
+	if err := db.Model(&models.LedgerEntry{}).Select("transaction_id, SUM(amount) AS total").
+		Group("transaction_id").Having("SUM(amount) <> 0").Scan(&unbalanced).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range unbalanced {
+		issues = append(issues, LedgerAuditIssue{TransactionID: t.TransactionID, Problem: "transaction does not balance", Actual: t.Total})
+	}
+
+	var accounts []struct {
+		ID      uint
+		Kind    string
+		Balance float64
+		Total   float64
+	}
+	if err := db.Model(&models.LedgerAccount{}).
+		Select("ledger_accounts.id, ledger_accounts.kind, ledger_accounts.balance, COALESCE(SUM(ledger_entries.amount), 0) AS total").
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.account_id = ledger_accounts.id").
+		Group("ledger_accounts.id").Scan(&accounts).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if RoundMoney(a.Balance) != RoundMoney(a.Total) {
+			issues = append(issues, LedgerAuditIssue{AccountID: a.ID, Problem: "balance differs from its entries", Expected: a.Total, Actual: a.Balance})
+		}
+		if a.Kind != "system" && a.Balance < 0 {
+			issues = append(issues, LedgerAuditIssue{AccountID: a.ID, Problem: "account is overdrawn", Actual: a.Balance})
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Loyalty ledger entry types.
+const (
+	LoyaltyEarn    = "earn"    // points for a confirmed booking
+	LoyaltyRedeem  = "redeem"  // points paying for a booking
+	LoyaltyRestore = "restore" // redeemed points given back when the booking fell through
+	LoyaltyReverse = "reverse" // earned points taken back after a cancellation or refund
+	LoyaltyExpire  = "expire"
+)
+
+const (
+	defaultLoyaltyPointsPer100 = 5
+	defaultLoyaltyPointValue   = 1
+	defaultLoyaltyExpiryDays   = 365
+)
+
+var ErrInsufficientPoints = errors.New("not enough loyalty points")
+
+// envFloat reads a non-negative number from the environment.
+func envFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// LoyaltyPointsPer100 returns the points earned per 100 spent on seats. It is read
+// from LOYALTY_POINTS_PER_100 and falls back to 5.
+func LoyaltyPointsPer100() float64 {
+	return envFloat("LOYALTY_POINTS_PER_100", defaultLoyaltyPointsPer100)
+}
+
+// LoyaltyPointValue returns what one point pays for. It is read from
+// LOYALTY_POINT_VALUE and falls back to 1.
+func LoyaltyPointValue() float64 {
+	return envFloat("LOYALTY_POINT_VALUE", defaultLoyaltyPointValue)
+}
+
+// LoyaltyExpiry returns how long points are valid after they were earned. It is
+// read from LOYALTY_EXPIRY_DAYS and falls back to 365 days.
+func LoyaltyExpiry() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("LOYALTY_EXPIRY_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultLoyaltyExpiryDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// LoyaltyPointsFor is what a seat amount earns. Parking never earns points.
+func LoyaltyPointsFor(seatAmount float64) int {
+	return int(math.Floor(seatAmount * LoyaltyPointsPer100() / 100))
+}
+
+// lockLoyalty serialises ledger changes of one user by locking their user row.
+func lockLoyalty(tx *gorm.DB, userID uint) error {
+	return tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error
+}
+
+// ExpireLoyaltyPoints writes off what is left of a user's lapsed lots.
+func ExpireLoyaltyPoints(tx *gorm.DB, userID uint, now time.Time) error {
+	var lots []models.LoyaltyEntry
+	if err := tx.Where("user_id = ? AND remaining > 0 AND expires_at <= ?", userID, now).Find(&lots).Error; err != nil {
+		return err
+	}
+	for _, lot := range lots {
+		if err := tx.Model(&lot).Update("remaining", 0).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.LoyaltyEntry{
+			UserID: userID,
+			Type:   LoyaltyExpire,
+			Points: -lot.Remaining,
+			Note:   fmt.Sprintf("points earned on %s expired", lot.CreatedAt.Format("02 Jan 2006")),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoyaltyBalance returns a user's spendable points, ignoring lapsed lots.
+func LoyaltyBalance(db *gorm.DB, userID uint, now time.Time) (int, error) {
+	var balance struct {
+		Total   int
+		Expired int
+	}
+	err := db.Model(&models.LoyaltyEntry{}).
+		Select("COALESCE(SUM(points), 0) AS total, COALESCE(SUM(CASE WHEN remaining > 0 AND expires_at <= ? THEN remaining ELSE 0 END), 0) AS expired", now).
+		Where("user_id = ?", userID).
+		Scan(&balance).Error
+	return balance.Total - balance.Expired, err
+}
+
+// addLot credits points to a user as a new lot.
+func addLot(tx *gorm.DB, userID uint, bookingID *uint, entryType string, points int, note string) error {
+	expiresAt := time.Now().Add(LoyaltyExpiry())
+	return tx.Create(&models.LoyaltyEntry{
+		UserID:    userID,
+		BookingID: bookingID,
+		Type:      entryType,
+		Points:    points,
+		Remaining: points,
+		ExpiresAt: &expiresAt,
+		Note:      note,
+	}).Error
+}
+
+// takeFromLots deducts up to points from a user's lots, those earned by one booking first
+// and then by earliest expiry, and returns how many it took.
+func takeFromLots(tx *gorm.DB, userID uint, bookingID *uint, points int) (int, error) {
+	query := tx.Where("user_id = ? AND remaining > 0", userID)
+	if bookingID != nil {
+		query = query.Order(clause.Expr{SQL: "(booking_id = ? AND type = 'earn') DESC NULLS LAST", Vars: []interface{}{*bookingID}})
+	}
+	var lots []models.LoyaltyEntry
+	if err := query.Order("expires_at, id").Find(&lots).Error; err != nil {
+		return 0, err
+	}
+
+	taken := 0
+	for _, lot := range lots {
+		if taken == points {
+			break
+		}
+		take := min(lot.Remaining, points-taken)
+		if err := tx.Model(&lot).Update("remaining", lot.Remaining-take).Error; err != nil {
+			return 0, err
+		}
+		taken += take
+	}
+	return taken, nil
+}
+
+// RedeemLoyaltyPoints spends points of a user on a booking.
+func RedeemLoyaltyPoints(tx *gorm.DB, userID, bookingID uint, points int) error {
+	if err := lockLoyalty(tx, userID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := ExpireLoyaltyPoints(tx, userID, now); err != nil {
+		return err
+	}
+	balance, err := LoyaltyBalance(tx, userID, now)
+	if err != nil {
+		return err
+	}
+	if balance < points {
+		return fmt.Errorf("%w: %d available", ErrInsufficientPoints, balance)
+	}
+
+	if _, err := takeFromLots(tx, userID, nil, points); err != nil {
+		return err
+	}
+	return tx.Create(&models.LoyaltyEntry{
+		UserID:    userID,
+		BookingID: &bookingID,
+		Type:      LoyaltyRedeem,
+		Points:    -points,
+		Note:      fmt.Sprintf("paid for booking #%d", bookingID),
+	}).Error
+}
+
+// AwardLoyaltyPoints credits the points a confirmed booking earns on what was paid
+// for its seats, leaving out taxes and fees added to them and food. A booking
+// earns once, however often it is confirmed.
+func AwardLoyaltyPoints(tx *gorm.DB, booking *models.Booking) error {
+	points := LoyaltyPointsFor(booking.TotalAmount - booking.ParkingFee - booking.Fees - booking.Taxes - booking.FoodAmount)
+	if points <= 0 {
+		return nil
+	}
+	var earned int64
+	if err := tx.Model(&models.LoyaltyEntry{}).Where("booking_id = ? AND type = ?", booking.ID, LoyaltyEarn).Count(&earned).Error; err != nil {
+		return err
+	}
+	if earned > 0 {
+		return nil
+	}
+	if err := lockLoyalty(tx, booking.UserID); err != nil {
+		return err
+	}
+	return addLot(tx, booking.UserID, &booking.ID, LoyaltyEarn, points, fmt.Sprintf("booking #%d", booking.ID))
+}
+
+// bookingPoints sums the ledger entries of one type for a booking.
+func bookingPoints(tx *gorm.DB, bookingID uint, entryType string) (int, error) {
+	var points int
+	err := tx.Model(&models.LoyaltyEntry{}).Select("COALESCE(SUM(points), 0)").
+		Where("booking_id = ? AND type = ?", bookingID, entryType).Scan(&points).Error
+	return points, err
+}
+
+// bookingPointsUser returns the user of a booking's first ledger entry of one
+// type, who is not its holder any more once the booking was transferred; 0 if it
+// has none.
+func bookingPointsUser(tx *gorm.DB, bookingID uint, entryType string) (uint, error) {
+	var userIDs []uint
+	err := tx.Model(&models.LoyaltyEntry{}).Where("booking_id = ? AND type = ?", bookingID, entryType).
+		Order("id").Limit(1).Pluck("user_id", &userIDs).Error
+	if err != nil || len(userIDs) == 0 {
+		return 0, err
+	}
+	return userIDs[0], nil
+}
+
+// ReverseLoyaltyPoints takes back the points a booking earned on a refunded seat
+// amount; pass the booking's whole seat amount when it is cancelled. They are
+// taken from the user who earned them, and only points that user still has, so
+// the balance never turns negative.
+func ReverseLoyaltyPoints(tx *gorm.DB, booking *models.Booking, seatAmount float64, note string) error {
+	earned, err := bookingPoints(tx, booking.ID, LoyaltyEarn)
+	if err != nil || earned == 0 {
+		return err
+	}
+	reversed, err := bookingPoints(tx, booking.ID, LoyaltyReverse)
+	if err != nil {
+		return err
+	}
+	points := min(LoyaltyPointsFor(seatAmount), earned+reversed) // reversed is negative
+	if points <= 0 {
+		return nil
+	}
+	userID, err := bookingPointsUser(tx, booking.ID, LoyaltyEarn)
+	if err != nil {
+		return err
+	}
+
+	if err := lockLoyalty(tx, userID); err != nil {
+		return err
+	}
+	if err := ExpireLoyaltyPoints(tx, userID, time.Now()); err != nil {
+		return err
+	}
+	taken, err := takeFromLots(tx, userID, &booking.ID, points)
+	if err != nil || taken == 0 {
+		return err
+	}
+	return tx.Create(&models.LoyaltyEntry{
+		UserID:    userID,
+		BookingID: &booking.ID,
+		Type:      LoyaltyReverse,
+		Points:    -taken,
+		Note:      note,
+	}).Error
+}
+
+// RestoreLoyaltyPoints gives back up to points of those that paid for a booking
+// which was cancelled, expired or refunded, to the user who redeemed them. They
+// come back as a new lot.
+func RestoreLoyaltyPoints(tx *gorm.DB, booking *models.Booking, points int, note string) error {
+	restored, err := bookingPoints(tx, booking.ID, LoyaltyRestore)
+	if err != nil {
+		return err
+	}
+	points = min(points, booking.PointsUsed-restored)
+	if points <= 0 {
+		return nil
+	}
+	userID, err := bookingPointsUser(tx, booking.ID, LoyaltyRedeem)
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		userID = booking.UserID
+	}
+	if err := lockLoyalty(tx, userID); err != nil {
+		return err
+	}
+	return addLot(tx, userID, &booking.ID, LoyaltyRestore, points, note)
+}
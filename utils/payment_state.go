@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payment statuses.
+const (
+	PaymentInitiated         = "initiated"  // intent created, waiting for the customer
+	PaymentAuthorized        = "authorized" // funds reserved by the gateway
+	PaymentCapturing         = "capturing"  // capture sent to the gateway, outcome not yet recorded
+	PaymentCaptured          = "captured"   // money taken; the booking is confirmed
+	PaymentFailed            = "failed"     // declined or capture failed; may be retried
+	PaymentCancelled         = "cancelled"  // abandoned, or authorized for a booking that lapsed
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// paymentTransitions is the payment lifecycle: the statuses each status may move to.
+var paymentTransitions = map[string][]string{
+	PaymentInitiated:         {PaymentAuthorized, PaymentFailed, PaymentCancelled},
+	PaymentAuthorized:        {PaymentCapturing, PaymentCaptured, PaymentFailed, PaymentCancelled},
+	PaymentCapturing:         {PaymentCaptured, PaymentFailed},
+	PaymentCaptured:          {PaymentPartiallyRefunded, PaymentRefunded},
+	PaymentPartiallyRefunded: {PaymentPartiallyRefunded, PaymentRefunded},
+	PaymentFailed:            {PaymentInitiated}, // retry with a new intent
+}
+
+var ErrInvalidPaymentTransition = errors.New("invalid payment transition")
+
+// CanTransitionPayment reports whether a payment may move from one status to another.
+func CanTransitionPayment(from, to string) bool {
+	for _, next := range paymentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPaymentCaptured reports whether money was taken for a payment, even if some
+// of it has since been refunded.
+func IsPaymentCaptured(status string) bool {
+	return status == PaymentCaptured || status == PaymentPartiallyRefunded
+}
+
+// TransitionPayment moves a payment to a new status and records the step in its
+// history. Every status change goes through here. The update only applies if the
+// payment is still in the status it was loaded with, so two racing transitions
+// cannot both succeed; extra columns to change in the same update may be passed.
+func TransitionPayment(tx *gorm.DB, payment *models.Payment, to, reason string, extra map[string]interface{}) error {
+	from := payment.Status
+	if !CanTransitionPayment(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidPaymentTransition, from, to)
+	}
+
+	updates := map[string]interface{}{"status": to, "updated_at": time.Now()}
+	for column, value := range extra {
+		updates[column] = value
+	}
+	result := tx.Model(&models.Payment{}).Where("id = ? AND status = ?", payment.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: payment %d is no longer %s", ErrInvalidPaymentTransition, payment.ID, from)
+	}
+
+	if err := tx.Create(&models.PaymentTransition{
+		PaymentID:  payment.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+	}).Error; err != nil {
+		return err
+	}
+
+	payment.Status = to
+	return nil
+}
+
+// MigrateLegacyPaymentStatuses renames the "completed" status used before the
+// lifecycle existed to "captured".
+func MigrateLegacyPaymentStatuses(db *gorm.DB) error {
+	return db.Model(&models.Payment{}).Where("status = ?", "completed").Update("status", PaymentCaptured).Error
+}
+
+// ConfirmPaidBooking confirms a pending booking once its payment is captured:
+// the booking is confirmed, its invoice issued, its loyalty points credited, its
+// food orders passed to the counter and the show's seat count raised.
+func ConfirmPaidBooking(tx *gorm.DB, booking *models.Booking) error {
+	if err := tx.Model(booking).Update("status", "confirmed").Error; err != nil {
+		return err
+	}
+	if _, err := IssueInvoice(tx, booking); err != nil {
+		return err
+	}
+	if err := AwardLoyaltyPoints(tx, booking); err != nil {
+		return err
+	}
+	if err := ConfirmFoodOrders(tx, booking.ID); err != nil {
+		return err
+	}
+	return tx.Model(&models.Show{}).Where("id = ?", booking.ShowID).
+		Update("seats_booked", gorm.Expr("seats_booked + ?", booking.SeatsCount)).Error
+}
+
+// ExtraCharge is money taken from a paid booking's payment method after the
+// payment itself, on its own intent: a dearer seat exchange or a later food order.
+type ExtraCharge struct {
+	BookingID uint
+	ChargeTx  string
+	Amount    float64
+}
+
+// ExtraCharges lists the extra charges of the given bookings, or of every booking
+// when none is given, exchanges first and each kind in the order it was made.
+func ExtraCharges(tx *gorm.DB, bookingIDs ...uint) ([]ExtraCharge, error) {
+	scope := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("charge_tx <> ''")
+		if len(bookingIDs) > 0 {
+			db = db.Where("booking_id IN ?", bookingIDs)
+		}
+		return db.Order("id")
+	}
+
+	var exchanges []models.BookingExchange
+	if err := tx.Scopes(scope).Find(&exchanges).Error; err != nil {
+		return nil, err
+	}
+	var orders []models.FoodOrder
+	if err := tx.Scopes(scope).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+
+	charges := make([]ExtraCharge, 0, len(exchanges)+len(orders))
+	for _, exchange := range exchanges {
+		charges = append(charges, ExtraCharge{exchange.BookingID, exchange.ChargeTx, exchange.Difference})
+	}
+	for _, order := range orders {
+		charges = append(charges, ExtraCharge{order.BookingID, order.ChargeTx, order.Total})
+	}
+	return charges, nil
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"cineverse/models"
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const pdfTimeLayout = "Mon, 02 Jan 2006 03:04 PM"
+
+// newPDF starts an A4 document with a title line. The returned translator maps
+// UTF-8 text to the code page of the built-in fonts.
+func newPDF(title string) (*gofpdf.Fpdf, func(string) string) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, tr(title), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+	return pdf, tr
+}
+
+// pdfRow writes a "label: value" line.
+func pdfRow(pdf *gofpdf.Fpdf, tr func(string) string, label, value string) {
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(40, 7, tr(label), "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.MultiCell(0, 7, tr(value), "", "L", false)
+}
+
+func pdfBytes(pdf *gofpdf.Fpdf) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func formatMoney(amount float64) string {
+	return fmt.Sprintf("Rs. %.2f", amount)
+}
+
+func seatList(seats []models.BookingSeat) string {
+	codes := make([]string, 0, len(seats))
+	for _, seat := range seats {
+		if seat.Category != "" {
+			codes = append(codes, seat.SeatCode+" ("+seat.Category+")")
+		} else {
+			codes = append(codes, seat.SeatCode)
+		}
+	}
+	return strings.Join(codes, ", ")
+}
+
+// TicketPDF renders a printable ticket for a booking loaded with its seats,
+// user, food orders and Show.Movie / Show.Screen.Theatre, with the QR code PNG of
+// its e-ticket.
+func TicketPDF(booking *models.Booking, qrPNG []byte) ([]byte, error) {
+	pdf, tr := newPDF("CineVerse Ticket")
+	show := booking.Show
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.MultiCell(0, 9, tr(show.Movie.Title), "", "L", false)
+	pdf.Ln(2)
+
+	pdfRow(pdf, tr, "Booking", fmt.Sprintf("#%d", booking.ID))
+	pdfRow(pdf, tr, "Name", booking.User.FullName)
+	pdfRow(pdf, tr, "Show time", show.StartTime.Format(pdfTimeLayout))
+	if show.Language != "" {
+		pdfRow(pdf, tr, "Language", show.Language)
+	}
+	pdfRow(pdf, tr, "Theatre", show.Screen.Theatre.Name+", "+show.Screen.Theatre.Location)
+	pdfRow(pdf, tr, "Screen", show.Screen.Name)
+	pdfRow(pdf, tr, "Seats", fmt.Sprintf("%s (%d)", seatList(booking.Seats), len(booking.Seats)))
+	if booking.HasParking {
+		pdfRow(pdf, tr, "Parking", fmt.Sprintf("%s - %s", booking.VehicleType, formatMoney(booking.ParkingFee)))
+	} else {
+		pdfRow(pdf, tr, "Parking", "Not booked")
+	}
+	pdfRow(pdf, tr, "Amount paid", formatMoney(booking.TotalAmount))
+	for _, order := range booking.FoodOrders {
+		var items []string
+		for _, item := range order.Items {
+			items = append(items, fmt.Sprintf("%d x %s", item.Quantity, item.Name))
+		}
+		pdfRow(pdf, tr, "Food pickup", order.PickupCode+" - "+strings.Join(items, ", "))
+	}
+
+	if len(qrPNG) > 0 {
+		pdf.Ln(6)
+		opts := gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: false}
+		pdf.RegisterImageOptionsReader("qr", opts, bytes.NewReader(qrPNG))
+		pdf.ImageOptions("qr", pdf.GetX(), pdf.GetY(), 60, 60, true, opts, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 6, tr("Show this code at the entrance. Each seat can be admitted once."), "", "L", false)
+	}
+
+	return pdfBytes(pdf)
+}
+
+// InvoicePDF renders the tax invoice of a booking loaded like for TicketPDF.
+func InvoicePDF(booking *models.Booking, invoice *models.Invoice) ([]byte, error) {
+	pdf, tr := newPDF("Tax Invoice")
+	show := booking.Show
+
+	pdfRow(pdf, tr, "Invoice no.", invoice.Number)
+	pdfRow(pdf, tr, "Invoice date", invoice.IssuedAt.Format("02 Jan 2006"))
+	pdfRow(pdf, tr, "Booking", fmt.Sprintf("#%d", booking.ID))
+	pdfRow(pdf, tr, "Billed to", booking.User.FullName+" <"+booking.User.Email+">")
+	pdfRow(pdf, tr, "Supplier", show.Screen.Theatre.Name+", "+show.Screen.Theatre.Location)
+	pdf.Ln(4)
+
+	header := func(desc, qty, amount string) {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(120, 8, tr(desc), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 8, tr(qty), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(0, 8, tr(amount), "B", 1, "R", false, 0, "")
+	}
+	line := func(desc, qty string, amount float64, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 11)
+		pdf.CellFormat(120, 7, tr(desc), "", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 7, tr(qty), "", 0, "R", false, 0, "")
+		pdf.CellFormat(0, 7, tr(formatMoney(amount)), "", 1, "R", false, 0, "")
+	}
+
+	header("Description", "Qty", "Amount")
+	line(fmt.Sprintf("%s - %s", show.Movie.Title, show.StartTime.Format(pdfTimeLayout)), fmt.Sprint(len(booking.Seats)), invoice.SeatSubtotal, false)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.MultiCell(120, 5, tr("Seats: "+seatList(booking.Seats)), "", "L", false)
+	if invoice.Discount > 0 {
+		desc := "Discount"
+		for _, discount := range booking.Discounts {
+			desc += " " + discount.Code
+		}
+		line(desc, "", -invoice.Discount, false)
+	}
+	for _, charge := range booking.Charges {
+		if charge.Kind == "fee" && !charge.Inclusive {
+			qty := ""
+			if charge.RateType == "per_seat" {
+				qty = fmt.Sprint(len(booking.Seats))
+			}
+			line(charge.Name, qty, charge.Amount, false)
+		}
+	}
+	if invoice.ParkingFee > 0 {
+		line("Parking ("+booking.VehicleType+")", "1", invoice.ParkingFee, false)
+	}
+	if invoice.Food > 0 {
+		line("Food and beverages", "", invoice.Food, false)
+	}
+	pdf.Ln(2)
+	pdf.CellFormat(0, 1, "", "T", 1, "L", false, 0, "")
+
+	line("Taxable value", "", invoice.TaxableValue, false)
+	line(fmt.Sprintf("CGST @ %.2f%%", invoice.TaxRate/2), "", invoice.CGST, false)
+	line(fmt.Sprintf("SGST @ %.2f%%", invoice.TaxRate/2), "", invoice.SGST, false)
+	line("Total", "", invoice.Total, true)
+	if invoice.PointsPaid > 0 {
+		line(fmt.Sprintf("Paid with %d loyalty points", booking.PointsUsed), "", invoice.PointsPaid, false)
+		line("Paid online", "", invoice.Total-invoice.PointsPaid, false)
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 9)
+	note := "All prices are inclusive of GST."
+	if booking.Taxes > 0 {
+		note = "Taxes are charged on top of the prices above."
+	}
+	pdf.MultiCell(0, 5, tr(note+" This is a computer generated invoice."), "", "L", false)
+
+	return pdfBytes(pdf)
+}
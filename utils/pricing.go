@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoadPricingRules returns the active pricing rules scoped to a show, its movie or
+// its theatre, or to none, highest priority first. Among occupancy steps of the
+// same priority the highest threshold comes first.
+func LoadPricingRules(db *gorm.DB, show *models.Show) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	err := db.Where("active = ?", true).
+		Where("show_id IS NULL OR show_id = ?", show.ID).
+		Where("movie_id IS NULL OR movie_id = ?", show.MovieID).
+		Where("theatre_id IS NULL OR theatre_id = (SELECT theatre_id FROM screens WHERE screens.id = ?)", show.ScreenID).
+		Order("priority DESC, min_occupancy DESC, id").
+		Find(&rules).Error
+	return rules, err
+}
+
+// parseClock turns "HH:MM" into minutes after midnight.
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidatePricingRule checks the conditions and adjustment of a rule.
+func ValidatePricingRule(rule *models.PricingRule) error {
+	if rule.Name == "" {
+		return errors.New("rule name is required")
+	}
+	if rule.AdjustmentType != "percent" && rule.AdjustmentType != "flat" {
+		return errors.New(`adjustment type must be "percent" or "flat"`)
+	}
+	if rule.Value == 0 {
+		return errors.New("adjustment value cannot be zero")
+	}
+	if rule.AdjustmentType == "percent" && rule.Value <= -100 {
+		return errors.New("a percent discount must be less than 100")
+	}
+	for _, day := range rule.Days {
+		if day < 0 || day > 6 {
+			return errors.New("days are 0 (Sunday) to 6 (Saturday)")
+		}
+	}
+	for _, clock := range []string{rule.FromTime, rule.ToTime} {
+		if clock != "" {
+			if _, err := parseClock(clock); err != nil {
+				return err
+			}
+		}
+	}
+	if rule.MinHoursBefore < 0 {
+		return errors.New("hours before the show cannot be negative")
+	}
+	if rule.MinOccupancy < 0 || rule.MinOccupancy > 100 {
+		return errors.New("occupancy is a percentage between 0 and 100")
+	}
+	return nil
+}
+
+// pricingRuleMatches reports whether every condition of a rule holds for a show
+// booked at the given time. A time window whose end is before its start spans
+// midnight, e.g. 22:00 to 02:00.
+func pricingRuleMatches(rule *models.PricingRule, show *models.Show, now time.Time) bool {
+	start := show.StartTime.In(time.Local)
+
+	if len(rule.Days) > 0 {
+		found := false
+		for _, day := range rule.Days {
+			if time.Weekday(day) == start.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if rule.FromTime != "" || rule.ToTime != "" {
+		minute := start.Hour()*60 + start.Minute()
+		from, to := 0, 24*60
+		if rule.FromTime != "" {
+			from, _ = parseClock(rule.FromTime)
+		}
+		if rule.ToTime != "" {
+			to, _ = parseClock(rule.ToTime)
+		}
+		if from <= to && (minute < from || minute >= to) || from > to && minute < from && minute >= to {
+			return false
+		}
+	}
+
+	if rule.MinHoursBefore > 0 && now.After(show.StartTime.Add(-time.Duration(rule.MinHoursBefore)*time.Hour)) {
+		return false
+	}
+
+	if rule.MinOccupancy > 0 {
+		if show.SeatsTotal <= 0 || float64(show.SeatsBooked)*100/float64(show.SeatsTotal) < rule.MinOccupancy {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchPricingRule returns the first of the rules, as ordered by LoadPricingRules,
+// that holds for a show booked now, or nil.
+func MatchPricingRule(rules []models.PricingRule, show *models.Show, now time.Time) *models.PricingRule {
+	for i := range rules {
+		if pricingRuleMatches(&rules[i], show, now) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// ApplyPricingRule adjusts a seat price by a rule. Prices never drop below zero.
+func ApplyPricingRule(rule *models.PricingRule, price float64) float64 {
+	if rule == nil {
+		return price
+	}
+	adjusted := price + rule.Value
+	if rule.AdjustmentType == "percent" {
+		adjusted = price * (1 + rule.Value/100)
+	}
+	return RoundMoney(max(adjusted, 0))
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"cineverse/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPromotionNotApplicable is wrapped by every reason a promo code is refused;
+// the message is meant for the customer.
+var ErrPromotionNotApplicable = errors.New("promo code cannot be applied")
+
+// redeemingBookings limits a query on booking_discounts to the bookings that use
+// up a redemption: confirmed ones and live holds.
+const redeemingBookings = "JOIN bookings ON bookings.id = booking_discounts.booking_id AND " +
+	"(bookings.status = 'confirmed' OR (bookings.status = 'pending' AND (bookings.expires_at IS NULL OR bookings.expires_at > ?)))"
+
+// NormalizePromoCode is the form promo codes are stored and looked up in.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromotionDiscount is the discount a promotion gives on a seat subtotal. It never
+// exceeds the subtotal, so a booking cannot become negative.
+func PromotionDiscount(promo *models.Promotion, seatSubtotal float64) float64 {
+	var discount float64
+	switch promo.DiscountType {
+	case "percent":
+		discount = seatSubtotal * promo.Value / 100
+		if promo.MaxDiscount > 0 && discount > promo.MaxDiscount {
+			discount = promo.MaxDiscount
+		}
+	case "flat":
+		discount = promo.Value
+	}
+	return RoundMoney(min(discount, seatSubtotal))
+}
+
+// PromotionRedemptions counts the bookings using a promotion, all of them or those of one user.
+func PromotionRedemptions(db *gorm.DB, promotionID, userID uint, now time.Time) (int64, error) {
+	query := db.Model(&models.BookingDiscount{}).Joins(redeemingBookings, now).
+		Where("booking_discounts.promotion_id = ?", promotionID)
+	if userID != 0 {
+		query = query.Where("bookings.user_id = ?", userID)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// ApplyPromotion checks a promo code against a booking being made: the campaign
+// window, its movie, theatre and show scope, the minimum seats and the usage
+// limits. The promotion row is locked, so concurrent bookings cannot redeem it
+// beyond its limit; call it inside the booking's transaction.
+func ApplyPromotion(tx *gorm.DB, code string, userID uint, show *models.Show, theatreID uint, seats int, seatSubtotal float64, now time.Time) (*models.Promotion, float64, error) {
+	var promo models.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ? AND active = ?", NormalizePromoCode(code), true).
+		First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, fmt.Errorf("%w: unknown promo code", ErrPromotionNotApplicable)
+		}
+		return nil, 0, err
+	}
+
+	switch {
+	case promo.StartsAt != nil && now.Before(*promo.StartsAt):
+		return nil, 0, fmt.Errorf("%w: the offer starts on %s", ErrPromotionNotApplicable, promo.StartsAt.Format("02 Jan 2006"))
+	case promo.EndsAt != nil && !now.Before(*promo.EndsAt):
+		return nil, 0, fmt.Errorf("%w: the offer has ended", ErrPromotionNotApplicable)
+	case promo.MovieID != nil && *promo.MovieID != show.MovieID,
+		promo.TheatreID != nil && *promo.TheatreID != theatreID,
+		promo.ShowID != nil && *promo.ShowID != show.ID:
+		return nil, 0, fmt.Errorf("%w: not valid for this show", ErrPromotionNotApplicable)
+	case seats < promo.MinSeats:
+		return nil, 0, fmt.Errorf("%w: book at least %d seats", ErrPromotionNotApplicable, promo.MinSeats)
+	}
+
+	if promo.UsageLimit > 0 {
+		used, err := PromotionRedemptions(tx, promo.ID, 0, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		if used >= int64(promo.UsageLimit) {
+			return nil, 0, fmt.Errorf("%w: the offer is fully redeemed", ErrPromotionNotApplicable)
+		}
+	}
+	if promo.PerUserLimit > 0 {
+		used, err := PromotionRedemptions(tx, promo.ID, userID, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		if used >= int64(promo.PerUserLimit) {
+			return nil, 0, fmt.Errorf("%w: you have already used this code", ErrPromotionNotApplicable)
+		}
+	}
+
+	return &promo, PromotionDiscount(&promo, seatSubtotal), nil
+}
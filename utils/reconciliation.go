@@ -0,0 +1,566 @@
+package utils
+
+import (
+	"cineverse/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reconciliation issue kinds.
+const (
+	IssueConfirmedWithoutCapture = "confirmed_without_capture" // confirmed booking, payment not captured
+	IssuePendingButPaid          = "pending_but_paid"          // money taken, booking still pending
+	IssuePaidAfterRelease        = "paid_after_release"        // money taken for a booking that released its seats
+	IssueUnknownTransaction      = "unknown_transaction"       // settled payment we have no record of
+	IssueAmountMismatch          = "amount_mismatch"
+	IssueStatusMismatch          = "status_mismatch"
+	IssueMissingSettlement       = "missing_settlement" // captured payment absent from the gateway's records
+	IssueUnknownRefund           = "unknown_refund"     // gateway refund absent from the ledger
+	IssueRefundNotSettled        = "refund_not_settled" // ledger refund absent from the gateway's records
+)
+
+var settlementStatuses = map[string]string{
+	"captured": "captured", "settled": "captured", "success": "captured", "succeeded": "captured", "paid": "captured",
+	"failed": "failed", "declined": "failed",
+	"refunded": "refunded", "refund": "refunded",
+}
+
+var settlementTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// ImportSettlementCSV loads a gateway settlement file. The first line names the
+// columns; transaction_id, amount and status are required, type ("payment" or
+// "refund", default payment), settled_at and reference are optional. The whole
+// file is rejected if any line is invalid.
+func ImportSettlementCSV(db *gorm.DB, gateway, filename string, r io.Reader, adminID *uint) (*models.SettlementImport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"transaction_id", "amount", "status"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []models.SettlementRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		record := models.SettlementRecord{
+			Gateway:       gateway,
+			TransactionID: field(row, "transaction_id"),
+			Type:          strings.ToLower(field(row, "type")),
+			Reference:     field(row, "reference"),
+			SettledAt:     time.Now(),
+		}
+		if record.TransactionID == "" {
+			return nil, fmt.Errorf("line %d: transaction_id is empty", line)
+		}
+		if record.Type == "" {
+			record.Type = "payment"
+		}
+		if record.Type != "payment" && record.Type != "refund" {
+			return nil, fmt.Errorf("line %d: unknown type %q", line, record.Type)
+		}
+		status, ok := settlementStatuses[strings.ToLower(field(row, "status"))]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown status %q", line, field(row, "status"))
+		}
+		record.Status = status
+		if record.Amount, err = strconv.ParseFloat(field(row, "amount"), 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, field(row, "amount"))
+		}
+		record.Amount = RoundMoney(record.Amount)
+		if raw := field(row, "settled_at"); raw != "" {
+			parsed := false
+			for _, layout := range settlementTimeLayouts {
+				if t, err := time.Parse(layout, raw); err == nil {
+					record.SettledAt, parsed = t, true
+					break
+				}
+			}
+			if !parsed {
+				return nil, fmt.Errorf("line %d: invalid settled_at %q", line, raw)
+			}
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the file has no transactions")
+	}
+
+	imported := models.SettlementImport{Gateway: gateway, Filename: filename, Rows: len(records), AdminID: adminID}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&imported).Error; err != nil {
+			return err
+		}
+		for i := range records {
+			records[i].ImportID = imported.ID
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "gateway"}, {Name: "transaction_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"import_id", "status", "amount", "reference", "settled_at"}),
+		}).CreateInBatches(&records, 500).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &imported, nil
+}
+
+// capturedPaymentStatuses are the statuses of payments whose money was taken.
+var capturedPaymentStatuses = []string{PaymentCaptured, PaymentPartiallyRefunded, PaymentRefunded}
+
+type reconciler struct {
+	db  *gorm.DB
+	run *models.ReconciliationRun
+	// settled payment records by gateway and transaction ID
+	settled map[string]models.SettlementRecord
+}
+
+func settlementKey(gateway, transactionID string) string {
+	return gateway + "|" + transactionID
+}
+
+func (r *reconciler) report(kind string, bookingID, paymentID *uint, transactionID, details string) (*models.ReconciliationIssue, error) {
+	issue := models.ReconciliationIssue{
+		RunID:         r.run.ID,
+		Kind:          kind,
+		BookingID:     bookingID,
+		PaymentID:     paymentID,
+		TransactionID: transactionID,
+		Details:       details,
+	}
+	if err := r.db.Create(&issue).Error; err != nil {
+		return nil, err
+	}
+	r.run.Mismatches++
+	return &issue, nil
+}
+
+// fix applies a safe correction for an issue, if the run may fix things, and
+// records what was done on the issue. A fix that cannot be applied is noted on
+// the issue; only failing to record the outcome is an error.
+func (r *reconciler) fix(issue *models.ReconciliationIssue, action string, apply func(tx *gorm.DB) error) error {
+	if !r.run.AutoFix {
+		return nil
+	}
+
+	var confirmed *models.Booking
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var before models.Booking
+		if issue.BookingID != nil {
+			tx.First(&before, *issue.BookingID)
+		}
+		if err := apply(tx); err != nil {
+			return err
+		}
+		if issue.BookingID != nil && before.Status == "pending" {
+			var booking models.Booking
+			if tx.First(&booking, *issue.BookingID).Error == nil && booking.Status == "confirmed" {
+				confirmed = &booking
+			}
+		}
+		return tx.Model(issue).Updates(map[string]interface{}{"fixed": true, "fix_action": action}).Error
+	})
+	if err != nil {
+		return r.db.Model(issue).Update("fix_action", "not fixed: "+err.Error()).Error
+	}
+	r.run.Fixed++
+
+	if confirmed != nil {
+		var seats []string
+		r.db.Model(&models.BookingSeat{}).Where("booking_id = ? AND active = ?", confirmed.ID, true).Pluck("seat_code", &seats)
+		SeatEvents.Publish(confirmed.ShowID, "booked", seats)
+	}
+	return nil
+}
+
+// capturePayment moves an initiated or authorized payment to captured.
+func (r *reconciler) capturePayment(tx *gorm.DB, paymentID uint) error {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("reconciliation run #%d: settled by gateway", r.run.ID)
+	if payment.Status == PaymentInitiated {
+		if err := TransitionPayment(tx, &payment, PaymentAuthorized, reason, nil); err != nil {
+			return err
+		}
+	}
+	return TransitionPayment(tx, &payment, PaymentCaptured, reason, nil)
+}
+
+// confirmBooking confirms a booking that is still pending, and so still holds its seats.
+func (r *reconciler) confirmBooking(tx *gorm.DB, bookingID uint) error {
+	var booking models.Booking
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, bookingID).Error; err != nil {
+		return err
+	}
+	if booking.Status != "pending" {
+		return fmt.Errorf("booking is %s", booking.Status)
+	}
+	return ConfirmPaidBooking(tx, &booking)
+}
+
+// paymentRow is a payment with the status of its booking.
+type paymentRow struct {
+	models.Payment
+	BookingStatus string
+}
+
+func (r *reconciler) checkBookings() error {
+	// Confirmed bookings whose payment was never captured. Bookings that points
+	// or discounts paid for entirely are confirmed without a payment, and so are
+	// those holding seats transferred out of a booking that paid for them.
+	var unpaid []struct {
+		BookingID uint
+		PaymentID *uint
+		Status    *string
+		Gateway   *string
+		Tx        *string
+	}
+	if err := r.db.Table("bookings").
+		Select("bookings.id AS booking_id, payments.id AS payment_id, payments.status AS status, payments.gateway AS gateway, payments.provider_tx AS tx").
+		Joins("LEFT JOIN payments ON payments.booking_id = bookings.id").
+		Where("bookings.status = ? AND (payments.id IS NULL AND bookings.total_amount > 0 AND bookings.source_booking_id IS NULL OR payments.status NOT IN ?)", "confirmed", capturedPaymentStatuses).
+		Scan(&unpaid).Error; err != nil {
+		return err
+	}
+	for _, row := range unpaid {
+		bookingID := row.BookingID
+		if row.PaymentID == nil {
+			if _, err := r.report(IssueConfirmedWithoutCapture, &bookingID, nil, "", "confirmed booking has no payment"); err != nil {
+				return err
+			}
+			continue
+		}
+		record, settled := r.settled[settlementKey(*row.Gateway, *row.Tx)]
+		issue, err := r.report(IssueConfirmedWithoutCapture, &bookingID, row.PaymentID, *row.Tx,
+			fmt.Sprintf("confirmed booking, payment is %s", *row.Status))
+		if err != nil {
+			return err
+		}
+		if settled && record.Status == "captured" && (*row.Status == PaymentInitiated || *row.Status == PaymentAuthorized) {
+			paymentID := *row.PaymentID
+			if err := r.fix(issue, "payment marked captured from settlement", func(tx *gorm.DB) error {
+				return r.capturePayment(tx, paymentID)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Payments of bookings that are not confirmed
+	var rows []paymentRow
+	if err := r.db.Model(&models.Payment{}).
+		Select("payments.*, bookings.status AS booking_status").
+		Joins("JOIN bookings ON bookings.id = payments.booking_id").
+		Where("bookings.status IN ?", []string{"pending", "expired", "cancelled"}).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		bookingID, paymentID := row.BookingID, row.ID
+		record, settled := r.settled[settlementKey(row.Gateway, row.ProviderTx)]
+		capturedHere := row.Status == PaymentCaptured
+		capturedThere := settled && record.Status == "captured" && !IsPaymentCaptured(row.Status) && row.Status != PaymentRefunded
+		if !capturedHere && !capturedThere {
+			continue
+		}
+
+		switch row.BookingStatus {
+		case "pending":
+			issue, err := r.report(IssuePendingButPaid, &bookingID, &paymentID, row.ProviderTx,
+				fmt.Sprintf("booking is pending, payment is %s, gateway settled: %t", row.Status, capturedThere))
+			if err != nil {
+				return err
+			}
+			if row.Status == PaymentInitiated || row.Status == PaymentAuthorized || capturedHere {
+				if err := r.fix(issue, "booking confirmed", func(tx *gorm.DB) error {
+					if !capturedHere {
+						if err := r.capturePayment(tx, paymentID); err != nil {
+							return err
+						}
+					}
+					return r.confirmBooking(tx, bookingID)
+				}); err != nil {
+					return err
+				}
+			}
+		case "expired":
+			if _, err := r.report(IssuePaidAfterRelease, &bookingID, &paymentID, row.ProviderTx,
+				fmt.Sprintf("booking expired but payment of %.2f was taken; refund it", row.Amount)); err != nil {
+				return err
+			}
+		case "cancelled":
+			if capturedThere {
+				if _, err := r.report(IssuePaidAfterRelease, &bookingID, &paymentID, row.ProviderTx,
+					fmt.Sprintf("booking cancelled, payment is %s but the gateway settled %.2f", row.Status, record.Amount)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *reconciler) checkSettlements() error {
+	var payments []models.Payment
+	if err := r.db.Where("provider_tx <> ''").Find(&payments).Error; err != nil {
+		return err
+	}
+	// A cart checkout is one gateway payment shared by the payments of its bookings
+	byTx := make(map[string][]*models.Payment)
+	for i := range payments {
+		key := settlementKey(payments[i].Gateway, payments[i].ProviderTx)
+		byTx[key] = append(byTx[key], &payments[i])
+	}
+
+	// Extra charges of seat exchanges and food orders are captured on their own intents
+	charges, err := ExtraCharges(r.db)
+	if err != nil {
+		return err
+	}
+	charged := make(map[uint]float64)
+	chargeByTx := make(map[string]ExtraCharge)
+	for _, charge := range charges {
+		charged[charge.BookingID] += charge.Amount
+		chargeByTx[charge.ChargeTx] = charge
+	}
+
+	// Wallet top-ups are gateway payments without a booking
+	var topups []models.WalletTopup
+	if err := r.db.Where("provider_tx <> ''").Find(&topups).Error; err != nil {
+		return err
+	}
+	topupByTx := make(map[string]models.WalletTopup)
+	for _, topup := range topups {
+		topupByTx[settlementKey(topup.Gateway, topup.ProviderTx)] = topup
+	}
+
+	var records []models.SettlementRecord
+	if err := r.db.Order("settled_at").Find(&records).Error; err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	refundRefs := make(map[string]models.SettlementRecord)
+	ranges := make(map[string][2]time.Time)
+	for _, record := range records {
+		span, ok := ranges[record.Gateway]
+		if !ok {
+			span[0] = record.SettledAt
+		}
+		span[1] = record.SettledAt
+		ranges[record.Gateway] = span
+
+		if record.Type == "refund" {
+			refundRefs[record.TransactionID] = record
+			continue
+		}
+		key := settlementKey(record.Gateway, record.TransactionID)
+		seen[key] = true
+
+		shares, ok := byTx[key]
+		if !ok {
+			if charge, isCharge := chargeByTx[record.TransactionID]; isCharge {
+				if record.Status == "captured" && RoundMoney(record.Amount) != RoundMoney(charge.Amount) {
+					bookingID := charge.BookingID
+					if _, err := r.report(IssueAmountMismatch, &bookingID, nil, record.TransactionID,
+						fmt.Sprintf("extra charge of %.2f settled as %.2f", charge.Amount, record.Amount)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if topup, isTopup := topupByTx[key]; isTopup {
+				if record.Status == "captured" && RoundMoney(record.Amount) != RoundMoney(topup.Amount) {
+					if _, err := r.report(IssueAmountMismatch, nil, nil, record.TransactionID,
+						fmt.Sprintf("wallet top-up #%d of %.2f settled as %.2f", topup.ID, topup.Amount, record.Amount)); err != nil {
+						return err
+					}
+				}
+				if record.Status == "captured" && topup.Status != PaymentCaptured {
+					if _, err := r.report(IssueStatusMismatch, nil, nil, record.TransactionID,
+						fmt.Sprintf("gateway settled wallet top-up #%d but it is %s", topup.ID, topup.Status)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if _, err := r.report(IssueUnknownTransaction, nil, nil, record.TransactionID,
+				fmt.Sprintf("gateway %s reports a %s payment of %.2f we have no record of", record.Gateway, record.Status, record.Amount)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var expected float64
+		for _, payment := range shares {
+			expected += payment.Amount - charged[payment.BookingID]
+		}
+		expected = RoundMoney(expected)
+		if record.Status == "captured" && record.Amount != expected {
+			bookingID, paymentID := shares[0].BookingID, shares[0].ID
+			if _, err := r.report(IssueAmountMismatch, &bookingID, &paymentID, record.TransactionID,
+				fmt.Sprintf("payment of %.2f settled as %.2f", expected, record.Amount)); err != nil {
+				return err
+			}
+		}
+		for _, payment := range shares {
+			bookingID, paymentID := payment.BookingID, payment.ID
+			switch {
+			case record.Status == "failed" && IsPaymentCaptured(payment.Status):
+				if _, err := r.report(IssueStatusMismatch, &bookingID, &paymentID, record.TransactionID,
+					"gateway reports the payment failed but it is "+payment.Status); err != nil {
+					return err
+				}
+			case record.Status == "refunded" && payment.Status != PaymentRefunded:
+				if _, err := r.report(IssueStatusMismatch, &bookingID, &paymentID, record.TransactionID,
+					"gateway reports the payment refunded but it is "+payment.Status); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Captured payments the gateway has not reported, within the period its files cover
+	for i := range payments {
+		payment := &payments[i]
+		span, ok := ranges[payment.Gateway]
+		if !ok || !IsPaymentCaptured(payment.Status) && payment.Status != PaymentRefunded {
+			continue
+		}
+		if payment.CreatedAt.Before(span[0].Add(-24*time.Hour)) || payment.CreatedAt.After(span[1]) {
+			continue
+		}
+		if !seen[settlementKey(payment.Gateway, payment.ProviderTx)] {
+			bookingID, paymentID := payment.BookingID, payment.ID
+			if _, err := r.report(IssueMissingSettlement, &bookingID, &paymentID, payment.ProviderTx,
+				fmt.Sprintf("captured payment of %.2f is not in the settlement records", payment.Amount)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Refunds on both sides; refunds into wallets never reach the gateway
+	var refunds []models.Refund
+	if err := r.db.Where("provider_ref <> '' AND destination <> ?", "wallet").Find(&refunds).Error; err != nil {
+		return err
+	}
+	ledgerRefs := make(map[string]bool)
+	for _, refund := range refunds {
+		refs := strings.Split(refund.ProviderRef, ",")
+		for _, ref := range refs {
+			ledgerRefs[ref] = true
+		}
+		if len(refs) == 1 {
+			if record, ok := refundRefs[refs[0]]; ok && record.Amount != RoundMoney(refund.Amount) {
+				bookingID, paymentID := refund.BookingID, refund.PaymentID
+				if _, err := r.report(IssueAmountMismatch, &bookingID, &paymentID, refs[0],
+					fmt.Sprintf("refund of %.2f settled as %.2f", refund.Amount, record.Amount)); err != nil {
+					return err
+				}
+			}
+		}
+
+		var payment models.Payment
+		if err := r.db.First(&payment, refund.PaymentID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		span, ok := ranges[payment.Gateway]
+		if !ok || refund.CreatedAt.Before(span[0]) || refund.CreatedAt.After(span[1]) {
+			continue
+		}
+		for _, ref := range refs {
+			if _, ok := refundRefs[ref]; !ok {
+				bookingID, paymentID := refund.BookingID, refund.PaymentID
+				if _, err := r.report(IssueRefundNotSettled, &bookingID, &paymentID, ref,
+					fmt.Sprintf("refund #%d of %.2f is not in the settlement records", refund.ID, refund.Amount)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for ref, record := range refundRefs {
+		if !ledgerRefs[ref] {
+			if _, err := r.report(IssueUnknownRefund, nil, nil, ref,
+				fmt.Sprintf("gateway %s reports a refund of %.2f that is not in the ledger", record.Gateway, record.Amount)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunReconciliation compares bookings, payments and the imported settlement
+// records and stores every mismatch on a new run. With autoFix the safe cases are
+// corrected, each fix recorded on its issue:
+//
+//   - a settled payment still initiated or authorized is marked captured
+//   - a pending booking whose payment was captured is confirmed
+//
+// Everything else, such as money taken for a booking whose seats were released,
+// is only reported for an admin to resolve.
+func RunReconciliation(db *gorm.DB, trigger string, adminID *uint, autoFix bool) (*models.ReconciliationRun, error) {
+	run := models.ReconciliationRun{Trigger: trigger, AdminID: adminID, AutoFix: autoFix, StartedAt: time.Now()}
+	if err := db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+
+	r := &reconciler{db: db, run: &run, settled: make(map[string]models.SettlementRecord)}
+	var records []models.SettlementRecord
+	if err := db.Where("type = ?", "payment").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		r.settled[settlementKey(record.Gateway, record.TransactionID)] = record
+	}
+
+	if err := r.checkBookings(); err != nil {
+		return nil, err
+	}
+	if err := r.checkSettlements(); err != nil {
+		return nil, err
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if err := db.Model(&run).Updates(map[string]interface{}{
+		"mismatches":  run.Mismatches,
+		"fixed":       run.Fixed,
+		"finished_at": finished,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
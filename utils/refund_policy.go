@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefundTier refunds Percent of the seat amount when a booking is cancelled at
+// least Before ahead of the show.
+type RefundTier struct {
+	Before  time.Duration
+	Percent float64
+}
+
+// RefundPolicy decides how much of a cancelled booking is paid back. Seats follow
+// the first tier whose notice period is met; parking is refunded separately at
+// ParkingPercent and food orders in full, as long as the show has not started.
+type RefundPolicy struct {
+	Tiers          []RefundTier // longest notice first
+	ParkingPercent float64
+}
+
+// RefundQuote is the outcome of applying a RefundPolicy to one booking.
+type RefundQuote struct {
+	SeatPercent   float64 `json:"seat_percent"`
+	SeatRefund    float64 `json:"seat_refund"`
+	ParkingRefund float64 `json:"parking_refund"`
+	FoodRefund    float64 `json:"food_refund"`
+	Total         float64 `json:"total"`
+}
+
+var defaultRefundTiers = []RefundTier{
+	{Before: 24 * time.Hour, Percent: 100},
+	{Before: 2 * time.Hour, Percent: 50},
+}
+
+// LoadRefundPolicy reads the policy from the environment:
+//
+//	REFUND_POLICY="24h:100,2h:50"   notice period and seat refund percent per tier
+//	PARKING_REFUND_PERCENT=100      parking refund before the show starts
+//
+// Missing or malformed values fall back to the defaults shown above.
+func LoadRefundPolicy() RefundPolicy {
+	policy := RefundPolicy{Tiers: defaultRefundTiers, ParkingPercent: 100}
+
+	if raw := os.Getenv("REFUND_POLICY"); raw != "" {
+		tiers, err := parseRefundTiers(raw)
+		if err != nil {
+			log.Printf("invalid REFUND_POLICY %q, using default: %v", raw, err)
+		} else {
+			policy.Tiers = tiers
+		}
+	}
+
+	if raw := os.Getenv("PARKING_REFUND_PERCENT"); raw != "" {
+		percent, err := strconv.ParseFloat(raw, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			log.Printf("invalid PARKING_REFUND_PERCENT %q, using default", raw)
+		} else {
+			policy.ParkingPercent = percent
+		}
+	}
+	return policy
+}
+
+func parseRefundTiers(raw string) ([]RefundTier, error) {
+	var tiers []RefundTier
+	for _, part := range strings.Split(raw, ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(fields) != 2 {
+			return nil, strconv.ErrSyntax
+		}
+		before, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		percent, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		if before < 0 || percent < 0 || percent > 100 {
+			return nil, strconv.ErrRange
+		}
+		tiers = append(tiers, RefundTier{Before: before, Percent: percent})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Before > tiers[j].Before })
+	return tiers, nil
+}
+
+// Quote works out the refund for cancelling at now a booking for a show starting
+// at showStart, given what was paid for seats, for parking and for food.
+func (p RefundPolicy) Quote(showStart, now time.Time, seatAmount, parkingAmount, foodAmount float64) RefundQuote {
+	var quote RefundQuote
+	notice := showStart.Sub(now)
+	if notice <= 0 {
+		return quote
+	}
+
+	for _, tier := range p.Tiers {
+		if notice >= tier.Before {
+			quote.SeatPercent = tier.Percent
+			break
+		}
+	}
+
+	quote.SeatRefund = RoundMoney(seatAmount * quote.SeatPercent / 100)
+	quote.ParkingRefund = RoundMoney(parkingAmount * p.ParkingPercent / 100)
+	quote.FoodRefund = RoundMoney(foodAmount)
+	quote.Total = RoundMoney(quote.SeatRefund + quote.ParkingRefund + quote.FoodRefund)
+	return quote
+}
+
+// Capped limits the quote to what is still refundable on the payment, e.g. after
+// an admin already gave part of it back. Parking and food keep their share first,
+// as in a full admin refund, and the seats take the rest.
+func (q RefundQuote) Capped(refundable float64) RefundQuote {
+	if q.Total <= refundable {
+		return q
+	}
+	refundable = math.Max(RoundMoney(refundable), 0)
+	q.ParkingRefund = math.Min(q.ParkingRefund, refundable)
+	q.FoodRefund = math.Min(q.FoodRefund, RoundMoney(refundable-q.ParkingRefund))
+	q.SeatRefund = RoundMoney(refundable - q.ParkingRefund - q.FoodRefund)
+	q.Total = refundable
+	return q
+}
+
+// RoundMoney rounds an amount to two decimal places.
+func RoundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
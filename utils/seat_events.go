@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// SeatEvent reports a change in the status of some seats of a show.
+// Status is "held", "released" or "booked".
+type SeatEvent struct {
+	ShowID uint      `json:"show_id"`
+	Status string    `json:"status"`
+	Seats  []string  `json:"seats"`
+	At     time.Time `json:"at"`
+}
+
+// SeatEventHub fans seat events out to the live seat-map streams of each show.
+// It is in-memory, so subscribers only see events published by this process.
+type SeatEventHub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan SeatEvent]struct{}
+}
+
+// allShows is the subscription key of SubscribeAll; show IDs start at 1.
+const allShows = 0
+
+// SeatEvents is the hub shared by the booking, payment and admin handlers.
+var SeatEvents = NewSeatEventHub()
+
+func NewSeatEventHub() *SeatEventHub {
+	return &SeatEventHub{subscribers: make(map[uint]map[chan SeatEvent]struct{})}
+}
+
+// Subscribe returns a channel receiving the events of one show and a function
+// that must be called to stop receiving them.
+func (h *SeatEventHub) Subscribe(showID uint) (<-chan SeatEvent, func()) {
+	ch := make(chan SeatEvent, 16)
+
+	h.mu.Lock()
+	if h.subscribers[showID] == nil {
+		h.subscribers[showID] = make(map[chan SeatEvent]struct{})
+	}
+	h.subscribers[showID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[showID][ch]; !ok {
+			return
+		}
+		delete(h.subscribers[showID], ch)
+		if len(h.subscribers[showID]) == 0 {
+			delete(h.subscribers, showID)
+		}
+		close(ch)
+	}
+}
+
+// SubscribeAll is Subscribe for the events of every show, for background
+// consumers such as the waitlist.
+func (h *SeatEventHub) SubscribeAll() (<-chan SeatEvent, func()) {
+	return h.Subscribe(allShows)
+}
+
+// Publish sends an event to every subscriber of the show. Subscribers that are
+// not keeping up miss the event rather than blocking the caller.
+func (h *SeatEventHub) Publish(showID uint, status string, seats []string) {
+	if len(seats) == 0 {
+		return
+	}
+	event := SeatEvent{ShowID: showID, Status: status, Seats: seats, At: time.Now()}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range []uint{showID, allShows} {
+		for ch := range h.subscribers[key] {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"cineverse/models"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSeatHoldMinutes      = 10
+	defaultWaitlistOfferMinutes = 15
+	defaultGroupClaimMinutes    = 24 * 60
+)
+
+// SeatHoldTTL returns how long a pending booking keeps its seats before it expires.
+// It is read from SEAT_HOLD_TTL_MINUTES and falls back to 10 minutes.
+func SeatHoldTTL() time.Duration {
+	return envMinutes("SEAT_HOLD_TTL_MINUTES", defaultSeatHoldMinutes)
+}
+
+// WaitlistOfferTTL returns how long a waitlisted user has to pay for the seats
+// offered to them. It is read from WAITLIST_OFFER_MINUTES and falls back to 15 minutes.
+func WaitlistOfferTTL() time.Duration {
+	return envMinutes("WAITLIST_OFFER_MINUTES", defaultWaitlistOfferMinutes)
+}
+
+// GroupClaimWindow returns how long the members of a group booking have by default
+// to claim and pay for their seats. It is read from GROUP_CLAIM_MINUTES and falls
+// back to 24 hours.
+func GroupClaimWindow() time.Duration {
+	return envMinutes("GROUP_CLAIM_MINUTES", defaultGroupClaimMinutes)
+}
+
+func envMinutes(key string, fallback int) time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || minutes <= 0 {
+		minutes = fallback
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// ExpireStaleHolds flips pending bookings whose hold has run out to "expired",
+// which releases their seats and parking slot, as it does group reservations past
+// their deadline. Bookings created before holds existed have no expires_at and
+// are expired once they are older than the TTL. Shows with an open waitlist are
+// left to the waitlist dispatcher, which offers the freed seats to the queue in
+// the same transaction. Bookings whose payment is being captured keep their seats
+// until the capture is recorded.
+func ExpireStaleHolds(db *gorm.DB) (int64, error) {
+	expired, released, err := expireHolds(db, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	// tell live seat maps which seats became free again
+	seatsByShow := make(map[uint][]string)
+	for _, seat := range released {
+		seatsByShow[seat.ShowID] = append(seatsByShow[seat.ShowID], seat.SeatCode)
+	}
+	for id, seats := range seatsByShow {
+		SeatEvents.Publish(id, "released", seats)
+	}
+	return expired, nil
+}
+
+// ExpireStaleHoldsForShow is ExpireStaleHolds limited to one show. CreateBooking
+// calls it inside its transaction so a lapsed hold never blocks a fresh booking
+// while the sweeper has yet to run. It returns the seat codes it freed; the
+// caller publishes them once its transaction has committed.
+func ExpireStaleHoldsForShow(tx *gorm.DB, showID uint) ([]string, error) {
+	_, released, err := expireHolds(tx, showID)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, len(released))
+	for _, seat := range released {
+		codes = append(codes, seat.SeatCode)
+	}
+	return codes, nil
+}
+
+func expireHolds(db *gorm.DB, showID uint) (int64, []models.BookingSeat, error) {
+	var released []models.BookingSeat
+	var expired int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Model(&models.Booking{}).
+			Where("status IN ?", []string{"pending", "reserved"}).
+			Where("expires_at <= ? OR (expires_at IS NULL AND created_at <= ?)", now, now.Add(-SeatHoldTTL())).
+			Where("id NOT IN (?)", tx.Model(&models.Payment{}).Select("booking_id").Where("status = ?", PaymentCapturing))
+		if showID != 0 {
+			query = query.Where("show_id = ?", showID)
+		} else {
+			query = query.Where("show_id NOT IN (?)", tx.Model(&models.WaitlistEntry{}).Select("show_id").
+				Where("status IN ?", []string{"waiting", "offered"}))
+		}
+
+		var bookingIDs []uint
+		if err := query.Pluck("id", &bookingIDs).Error; err != nil {
+			return err
+		}
+		if len(bookingIDs) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&models.Booking{}).
+			Where("id IN ? AND status IN ?", bookingIDs, []string{"pending", "reserved"}).
+			Updates(map[string]interface{}{
+				"status":     "expired",
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		expired = result.RowsAffected
+
+		// Points that paid for the lapsed bookings go back to their owners
+		var paidWithPoints []models.Booking
+		if err := tx.Where("id IN ? AND points_used > 0", bookingIDs).Find(&paidWithPoints).Error; err != nil {
+			return err
+		}
+		for i := range paidWithPoints {
+			if err := RestoreLoyaltyPoints(tx, &paidWithPoints[i], paidWithPoints[i].PointsUsed, "seat hold expired"); err != nil {
+				return err
+			}
+		}
+
+		// Food ordered with the lapsed bookings goes back into stock
+		if err := CancelFoodOrders(tx, bookingIDs); err != nil {
+			return err
+		}
+
+		// Payments still waiting on the customer will never be captured now
+		var open []models.Payment
+		if err := tx.Where("booking_id IN ? AND status IN ?", bookingIDs, []string{PaymentInitiated, PaymentAuthorized}).Find(&open).Error; err != nil {
+			return err
+		}
+		for i := range open {
+			if err := TransitionPayment(tx, &open[i], PaymentCancelled, "seat hold expired", nil); err != nil {
+				return err
+			}
+		}
+
+		// Carts whose checkout held these bookings were never paid
+		if err := tx.Model(&models.Cart{}).
+			Where("status = ? AND id IN (?)", "checkout", tx.Model(&models.Booking{}).Select("cart_id").Where("id IN ?", bookingIDs)).
+			Update("status", "expired").Error; err != nil {
+			return err
+		}
+
+		// Group bookings past their deadline take no more claims
+		var closed []uint
+		if err := tx.Model(&models.GroupBooking{}).Where("status = ? AND hold_booking_id IN ?", "open", bookingIDs).
+			Pluck("id", &closed).Error; err != nil {
+			return err
+		}
+		if len(closed) > 0 {
+			if err := tx.Model(&models.GroupBooking{}).Where("id IN ?", closed).Update("status", "closed").Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.GroupInvite{}).Where("group_id IN ? AND status = ?", closed, "invited").
+				Update("status", "lapsed").Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("booking_id IN ? AND active = ?", bookingIDs, true).Find(&released).Error; err != nil {
+			return err
+		}
+		return ReleaseInactiveSeats(tx, showID)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return expired, released, nil
+}
+
+// ReleaseInactiveSeats clears the active flag on seats whose booking is no longer
+// pending, confirmed or reserved, freeing them under the unique seat index. A
+// showID other than 0 limits it to that show, as booking transactions holding the
+// show's lock need.
+func ReleaseInactiveSeats(db *gorm.DB, showID uint) error {
+	query := db.Model(&models.BookingSeat{}).Where("active = ?", true)
+	if showID != 0 {
+		query = query.Where("show_id = ?", showID)
+	}
+	return query.
+		Where("booking_id IN (?)", db.Model(&models.Booking{}).Select("id").Where("status NOT IN (?, ?, ?)", "pending", "confirmed", "reserved")).
+		Update("active", false).Error
+}
+
+// StartSeatHoldSweeper runs ExpireStaleHolds every interval in the background.
+func StartSeatHoldSweeper(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			expired, err := ExpireStaleHolds(db)
+			if err != nil {
+				log.Printf("seat hold sweeper failed: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("seat hold sweeper expired %d booking(s)", expired)
+			}
+		}
+	}()
+}
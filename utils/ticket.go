@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/skip2/go-qrcode"
+)
+
+// TicketClaims is what a signed e-ticket encodes.
+type TicketClaims struct {
+	BookingID uint     `json:"bid"`
+	ShowID    uint     `json:"sid"`
+	Seats     []string `json:"seats"`
+	Version   int      `json:"ver,omitempty"` // the booking's TicketVersion when signed
+	jwt.RegisteredClaims
+}
+
+// ticketSecret signs e-tickets with TICKET_SECRET, which has to be set apart from
+// JWT_SECRET.
+func ticketSecret() ([]byte, error) {
+	secret, err := dedicatedSecret("TICKET_SECRET")
+	return []byte(secret), err
+}
+
+// CreateTicketToken signs a ticket for the seats of a booking, valid until expiresAt.
+// version is the booking's TicketVersion; tickets of an older version are void.
+func CreateTicketToken(bookingID, showID uint, seats []string, version int, expiresAt time.Time) (string, error) {
+	claims := TicketClaims{
+		BookingID: bookingID,
+		ShowID:    showID,
+		Seats:     seats,
+		Version:   version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "ticket",
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	secret, err := ticketSecret()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
+
+// ValidateTicketToken checks the signature and expiry of a ticket and returns its claims.
+func ValidateTicketToken(tokenStr string) (*TicketClaims, error) {
+	if tokenStr == "" {
+		return nil, errors.New("missing ticket")
+	}
+
+	token, err := jwt.ParseWithClaims(tokenStr, &TicketClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return ticketSecret()
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*TicketClaims)
+	if !ok || !token.Valid || claims.Subject != "ticket" {
+		return nil, errors.New("invalid ticket")
+	}
+	return claims, nil
+}
+
+// TicketQRCode renders a ticket token as a PNG QR code of the given size in pixels.
+func TicketQRCode(token string, size int) ([]byte, error) {
+	return qrcode.Encode(token, qrcode.Medium, size)
+}